@@ -14,7 +14,6 @@ type channelsGetter interface {
 }
 
 type channelsCollector struct {
-	ctx    context.Context
 	logger *slog.Logger
 
 	channelsGetter channelsGetter
@@ -27,7 +26,7 @@ func init() {
 	registerCollector("channel", defaultEnabled, newChannelsCollector)
 }
 
-func newChannelsCollector(ctx context.Context, client *mirakurun.Client, logger *slog.Logger) Collector {
+func newChannelsCollector(source *source, options *collectorOptions, logger *slog.Logger) Collector {
 	const subsystem = "channel"
 
 	metricDefs := map[string]metricDefinition{
@@ -52,8 +51,7 @@ func newChannelsCollector(ctx context.Context, client *mirakurun.Client, logger
 	}
 
 	return &channelsCollector{
-		ctx:            ctx,
-		channelsGetter: client,
+		channelsGetter: source,
 		logger:         logger,
 		metrics:        metrics,
 		metricTypes:    metricTypes,
@@ -66,8 +64,8 @@ func (c *channelsCollector) Describe(ch chan<- *prometheus.Desc) {
 	}
 }
 
-func (c *channelsCollector) Collect(ch chan<- prometheus.Metric) error {
-	channels, err := c.channelsGetter.GetChannels(c.ctx, c.logger)
+func (c *channelsCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric) error {
+	channels, err := c.channelsGetter.GetChannels(ctx, c.logger)
 	if err != nil {
 		return err
 	}
@@ -105,11 +105,11 @@ func TestChannelsCollector_Collect(t *testing.T) {
 				mock.err = assert.AnError
 			}
 
-			collector := newChannelsCollector(context.Background(), nil, slog.Default())
+			collector := newChannelsCollector(nil, &collectorOptions{}, slog.Default())
 			collector.(*channelsCollector).channelsGetter = mock
 
 			ch := make(chan prometheus.Metric, 100)
-			err := collector.Collect(ch)
+			err := collector.Collect(context.Background(), ch)
 			if tt.wantErr {
 				require.Error(t, err)
 				return
@@ -131,7 +131,7 @@ func TestChannelsCollector_Collect(t *testing.T) {
 }
 
 func TestChannelsCollector_Describe(t *testing.T) {
-	collector := newChannelsCollector(context.Background(), nil, slog.Default())
+	collector := newChannelsCollector(nil, &collectorOptions{}, slog.Default())
 	ch := make(chan *prometheus.Desc, 64)
 	collector.Describe(ch)
 
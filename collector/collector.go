@@ -2,10 +2,15 @@ package collector
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/alecthomas/kingpin/v2"
@@ -21,7 +26,10 @@ const (
 	defaultDisabled = false
 )
 
-type CollectorFactory func(ctx context.Context, client *mirakurun.Client, logger *slog.Logger) Collector
+// scrapeTimeoutHeader is set by Prometheus to the scrape timeout of the job.
+const scrapeTimeoutHeader = "X-Prometheus-Scrape-Timeout-Seconds"
+
+type CollectorFactory func(source *source, options *collectorOptions, logger *slog.Logger) Collector
 
 type metricDefinition struct {
 	name       string
@@ -36,6 +44,8 @@ var (
 	forcedCollectors = make(map[string]bool)
 
 	enableScrapeCollector = kingpin.Flag("collector.scrape", "Enable the scrape collector (default: true).").Default("true").Bool()
+	instanceLabelFlag     = kingpin.Flag("mirakurun.instance-label", "Label every metric with the URL of its Mirakurun instance as mirakurun_instance. --no-mirakurun.instance-label omits it when a single instance is scraped.").Default("true").Bool()
+	scrapeTimeoutOffset   = kingpin.Flag("scrape.timeout-offset", "Seconds to subtract from the Prometheus scrape timeout to leave time for sending the response.").Default("0.5").Float64()
 )
 
 var (
@@ -53,14 +63,42 @@ var (
 	)
 )
 
+// instanceLabel tells apart the metrics of the Mirakurun instances scraped by the exporter.
+const instanceLabel = "mirakurun_instance"
+
+func newScrapeErrorsTotal() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "scrape",
+			Name:      "collector_errors_total",
+			Help:      "mirakurun_exporter: Number of collector failures by reason",
+		},
+		[]string{"collector", "reason"},
+	)
+}
+
 type Collector interface {
 	Describe(ch chan<- *prometheus.Desc)
-	Collect(ch chan<- prometheus.Metric) error
+	Collect(ctx context.Context, ch chan<- prometheus.Metric) error
+}
+
+// backgroundCollector is implemented by collectors that follow a Mirakurun stream between scrapes.
+type backgroundCollector interface {
+	Collector
+	// Run follows the stream until ctx is done.
+	Run(ctx context.Context)
 }
 
 type MirakurunCollector struct {
 	Collectors map[string]Collector
+	client     *mirakurun.Client
+	source     *source
 	logger     *slog.Logger
+
+	// instance is the value of the mirakurun_instance label.
+	instance     string
+	scrapeErrors *prometheus.CounterVec
 }
 
 func registerCollector(collector string, isDefaultEnabled bool, factory CollectorFactory) {
@@ -96,18 +134,47 @@ func collectorFlagAction(collector string) func(ctx *kingpin.ParseContext) error
 	}
 }
 
-func MetricsHandler(client *mirakurun.Client, logger *slog.Logger) http.HandlerFunc {
+// MetricsHandler serves the metrics of mirakurunCollectors, one per Mirakurun instance, collected in parallel.
+// Every scrape runs with the request context, bounded by the scrape timeout announced by Prometheus.
+// The collect[] and exclude[] query parameters select the collectors run by the scrape.
+// The metrics of exporterGatherers, which describe the exporter itself, are served alongside.
+func MetricsHandler(mirakurunCollectors []*MirakurunCollector, logger *slog.Logger, exporterGatherers ...prometheus.Gatherer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		logger.Debug("metrics request", "url", r.URL.String())
-		registry := prometheus.NewRegistry()
-		mirakurunCollector, err := NewMirakurunCollector(r.Context(), client, logger)
+
+		names := make([][]string, len(mirakurunCollectors))
+		for i, mirakurunCollector := range mirakurunCollectors {
+			var err error
+			if names[i], err = selectCollectors(r.URL.Query(), mirakurunCollector.Collectors); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		ctx := r.Context()
+		timeout, err := scrapeTimeout(r, *scrapeTimeoutOffset)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("failed to create collector: %s", err), http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		registry.MustRegister(mirakurunCollector)
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		registry := prometheus.NewRegistry()
+		for i, mirakurunCollector := range mirakurunCollectors {
+			if err := registerInstance(registry, mirakurunCollector, len(mirakurunCollectors) > 1,
+				&scrapeCollector{ctx: ctx, collector: mirakurunCollector.only(names[i])},
+				mirakurunCollector.client,
+			); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
 
-		h := promhttp.HandlerFor(registry, promhttp.HandlerOpts{
+		h := promhttp.HandlerFor(append(prometheus.Gatherers{registry}, exporterGatherers...), promhttp.HandlerOpts{
 			ErrorLog:      slog.NewLogLogger(logger.Handler(), slog.LevelError),
 			ErrorHandling: promhttp.ContinueOnError,
 		})
@@ -115,43 +182,207 @@ func MetricsHandler(client *mirakurun.Client, logger *slog.Logger) http.HandlerF
 	}
 }
 
-func NewMirakurunCollector(ctx context.Context, client *mirakurun.Client, logger *slog.Logger) (*MirakurunCollector, error) {
+// selectCollectors returns the names of the collectors given by the collect[] query parameters, or of all
+// collectors without them, minus those given by exclude[]. It returns nil when neither is given.
+// Unknown collectors, and those given by collect[] but not among collectors, are errors.
+func selectCollectors(query url.Values, collectors map[string]Collector) ([]string, error) {
+	collect, exclude := query["collect[]"], query["exclude[]"]
+	if len(collect) == 0 && len(exclude) == 0 {
+		return nil, nil
+	}
+
+	for _, name := range collect {
+		if _, ok := collectors[name]; !ok {
+			return nil, fmt.Errorf("collector %q is unknown or disabled", name)
+		}
+	}
+	for _, name := range exclude {
+		if _, ok := collectors[name]; !ok && !IsKnown(name) {
+			return nil, fmt.Errorf("collector %q is unknown", name)
+		}
+	}
+
+	if len(collect) == 0 {
+		for name := range collectors {
+			collect = append(collect, name)
+		}
+	}
+	names := make([]string, 0, len(collect))
+	for _, name := range collect {
+		if !slices.Contains(exclude, name) && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// registerInstance registers collectors of the metrics of mirakurunCollector on registry. It fails when
+// another instance has the same label, which would otherwise panic on every scrape.
+func registerInstance(registry *prometheus.Registry, mirakurunCollector *MirakurunCollector, several bool, collectors ...prometheus.Collector) error {
+	registerer := instanceRegisterer(registry, mirakurunCollector, several)
+	for _, c := range collectors {
+		if err := registerer.Register(c); err != nil {
+			return fmt.Errorf("failed to register the metrics of %s: %w", mirakurunCollector.instance, err)
+		}
+	}
+	return nil
+}
+
+// instanceRegisterer returns the registerer of the metrics of mirakurunCollector, which labels every
+// metric with the instance it comes from. The label can only be left out when a single instance is scraped.
+func instanceRegisterer(registry *prometheus.Registry, mirakurunCollector *MirakurunCollector, several bool) prometheus.Registerer {
+	if !several && !*instanceLabelFlag {
+		return registry
+	}
+	return prometheus.WrapRegistererWith(prometheus.Labels{instanceLabel: mirakurunCollector.instance}, registry)
+}
+
+// scrapeTimeout returns the time a scrape may take according to the X-Prometheus-Scrape-Timeout-Seconds header,
+// reduced by offset to leave room for writing the response. It returns 0 if the header is absent.
+func scrapeTimeout(r *http.Request, offset float64) (time.Duration, error) {
+	header := r.Header.Get(scrapeTimeoutHeader)
+	if header == "" {
+		return 0, nil
+	}
+
+	seconds, err := strconv.ParseFloat(header, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s header: %w", scrapeTimeoutHeader, err)
+	}
+	if seconds-offset > 0 {
+		seconds -= offset
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// NewMirakurunCollector creates the collectors enabled by the flags and settings. It is meant to be
+// called once and shared between scrapes.
+func NewMirakurunCollector(client *mirakurun.Client, settings Settings, logger *slog.Logger) (*MirakurunCollector, error) {
+	options, err := settings.options()
+	if err != nil {
+		return nil, err
+	}
+
+	source := newSource(client)
 	collectors := make(map[string]Collector)
-	for key, enabled := range collectorState {
-		if !*enabled {
+	for key, enabled := range options.enabled {
+		if !enabled {
 			continue
 		}
-		collectors[key] = factories[key](ctx, client, logger)
+		collectors[key] = factories[key](source, options, logger)
+	}
+	return &MirakurunCollector{
+		Collectors:   collectors,
+		client:       client,
+		source:       source,
+		logger:       logger,
+		instance:     client.URL,
+		scrapeErrors: newScrapeErrorsTotal(),
+	}, nil
+}
+
+// Start runs the background collectors, such as the events collector, until ctx is done.
+func (mirakurunCollector *MirakurunCollector) Start(ctx context.Context) {
+	for name, c := range mirakurunCollector.Collectors {
+		if bc, ok := c.(backgroundCollector); ok {
+			mirakurunCollector.logger.Debug("starting background collector", "name", name)
+			go bc.Run(ctx)
+		}
 	}
-	return &MirakurunCollector{Collectors: collectors, logger: logger}, nil
 }
 
 func (mirakurunCollector *MirakurunCollector) Describe(ch chan<- *prometheus.Desc) {
 	for _, collector := range mirakurunCollector.Collectors {
 		collector.Describe(ch)
 	}
+	if *enableScrapeCollector {
+		ch <- scrapeDurationDesc
+		ch <- scrapeSuccessDesc
+		mirakurunCollector.scrapeErrors.Describe(ch)
+	}
+	if mirakurunCollector.source != nil {
+		ch <- compatibilityModeDesc
+	}
+}
+
+// Collect runs all collectors in parallel on a snapshot of Mirakurun. Each collector stops when ctx is done.
+func (mirakurunCollector *MirakurunCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric) {
+	mirakurunCollector.collect(ctx, ch)
+}
+
+// collect is Collect reporting whether all collectors succeeded.
+func (mirakurunCollector *MirakurunCollector) collect(ctx context.Context, ch chan<- prometheus.Metric) bool {
+	return mirakurunCollector.collectEach(ctx, func(string) chan<- prometheus.Metric { return ch })
 }
 
-func (mirakurunCollector *MirakurunCollector) Collect(ch chan<- prometheus.Metric) {
+// collectEach is collect sending the metrics of each collector, including its scrape metrics, to the
+// channel channelOf returns for its name, and the metrics of no single collector to that of "".
+func (mirakurunCollector *MirakurunCollector) collectEach(ctx context.Context, channelOf func(name string) chan<- prometheus.Metric) bool {
+	ctx = withSnapshot(ctx)
+	collectors := mirakurunCollector.Collectors
+	if mirakurunCollector.source != nil {
+		collectors = mirakurunCollector.compatibleCollectors(ctx, channelOf(""))
+	}
+
+	var failed atomic.Bool
 	wg := sync.WaitGroup{}
-	wg.Add(len(mirakurunCollector.Collectors))
-	for name, c := range mirakurunCollector.Collectors {
+	wg.Add(len(collectors))
+	for name, c := range collectors {
 		go func(name string, c Collector) {
-			executeCollect(name, c, ch, mirakurunCollector.logger)
+			if !executeCollect(ctx, name, c, channelOf(name), mirakurunCollector.scrapeErrors, mirakurunCollector.logger) {
+				failed.Store(true)
+			}
 			wg.Done()
 		}(name, c)
 	}
 	wg.Wait()
+	if *enableScrapeCollector {
+		mirakurunCollector.scrapeErrors.Collect(channelOf(""))
+	}
+	return !failed.Load()
+}
+
+// only returns a MirakurunCollector sharing the source of mirakurunCollector and running
+// only the named collectors. Nil names keep all collectors.
+func (mirakurunCollector *MirakurunCollector) only(names []string) *MirakurunCollector {
+	if names == nil {
+		return mirakurunCollector
+	}
+	collectors := make(map[string]Collector, len(names))
+	for name, c := range mirakurunCollector.Collectors {
+		if slices.Contains(names, name) {
+			collectors[name] = c
+		}
+	}
+	subset := *mirakurunCollector
+	subset.Collectors = collectors
+	return &subset
 }
 
-func executeCollect(name string, c Collector, ch chan<- prometheus.Metric, logger *slog.Logger) {
+// scrapeCollector binds a MirakurunCollector to the context of a single scrape.
+type scrapeCollector struct {
+	ctx       context.Context
+	collector *MirakurunCollector
+}
+
+func (s *scrapeCollector) Describe(ch chan<- *prometheus.Desc) {
+	s.collector.Describe(ch)
+}
+
+func (s *scrapeCollector) Collect(ch chan<- prometheus.Metric) {
+	s.collector.Collect(s.ctx, ch)
+}
+
+func executeCollect(ctx context.Context, name string, c Collector, ch chan<- prometheus.Metric, scrapeErrors *prometheus.CounterVec, logger *slog.Logger) bool {
 	begin := time.Now()
-	err := c.Collect(ch)
+	err := c.Collect(ctx, ch)
 	duration := time.Since(begin)
 	var success float64
 
 	if err != nil {
-		logger.Error("collector failed", "name", name, "duration_seconds", duration.Seconds(), "err", err)
+		reason := errorReason(err)
+		logger.Error("collector failed", "name", name, "duration_seconds", duration.Seconds(), "reason", reason, "err", err)
+		scrapeErrors.WithLabelValues(name, reason).Inc()
 		success = 0
 	} else {
 		logger.Debug("collector succeeded", "name", name, "duration_seconds", duration.Seconds())
@@ -161,6 +392,35 @@ func executeCollect(name string, c Collector, ch chan<- prometheus.Metric, logge
 		ch <- prometheus.MustNewConstMetric(scrapeDurationDesc, prometheus.GaugeValue, duration.Seconds(), name)
 		ch <- prometheus.MustNewConstMetric(scrapeSuccessDesc, prometheus.GaugeValue, success, name)
 	}
+	return err == nil
+}
+
+// errorReason classifies a collector error into the reason label of mirakurun_scrape_collector_errors_total.
+func errorReason(err error) string {
+	var (
+		connErr    *mirakurun.ConnectionRefusedError
+		timeoutErr *mirakurun.TimeoutError
+		requestErr *mirakurun.RequestError
+		statusErr  *mirakurun.StatusError
+		decodeErr  *mirakurun.DecodeError
+		sizeErr    *mirakurun.ResponseTooLargeError
+	)
+	switch {
+	case errors.As(err, &connErr):
+		return "connection_refused"
+	case errors.As(err, &timeoutErr), errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &statusErr):
+		return "http_status"
+	case errors.As(err, &decodeErr):
+		return "decode"
+	case errors.As(err, &sizeErr):
+		return "response_too_large"
+	case errors.As(err, &requestErr):
+		return "request"
+	default:
+		return "unknown"
+	}
 }
 
 func boolToFloat64(b bool) float64 {
@@ -0,0 +1,351 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nasshu2916/mirakurun_exporter/mirakurun"
+	"github.com/nasshu2916/mirakurun_exporter/mirakurun/fixture"
+	"github.com/nasshu2916/mirakurun_exporter/mirakuruntest"
+)
+
+type slowCollector struct{}
+
+func (c *slowCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (c *slowCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(10 * time.Second):
+		return nil
+	}
+}
+
+type errorCollector struct {
+	err error
+}
+
+func (c *errorCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (c *errorCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric) error {
+	return c.err
+}
+
+func TestErrorReason(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "connection refused", err: &mirakurun.ConnectionRefusedError{Path: "/api/status"}, want: "connection_refused"},
+		{name: "timeout", err: &mirakurun.TimeoutError{Path: "/api/status"}, want: "timeout"},
+		{name: "context deadline", err: context.DeadlineExceeded, want: "timeout"},
+		{name: "http status", err: &mirakurun.StatusError{Path: "/api/status", StatusCode: 500}, want: "http_status"},
+		{name: "decode", err: &mirakurun.DecodeError{Path: "/api/status"}, want: "decode"},
+		{name: "response too large", err: &mirakurun.ResponseTooLargeError{Path: "/api/programs"}, want: "response_too_large"},
+		{name: "request", err: &mirakurun.RequestError{Path: "/api/status", Err: errors.New("EOF")}, want: "request"},
+		{name: "wrapped", err: fmt.Errorf("wrapped: %w", &mirakurun.DecodeError{Path: "/api/status"}), want: "decode"},
+		{name: "unknown", err: errors.New("unknown"), want: "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, errorReason(tt.err))
+		})
+	}
+}
+
+func TestExecuteCollect_CountsErrors(t *testing.T) {
+	scrapeErrors := newScrapeErrorsTotal()
+	counter := scrapeErrors.WithLabelValues("test", "http_status")
+	before := testutil.ToFloat64(counter)
+
+	ch := make(chan prometheus.Metric, 10)
+	executeCollect(context.Background(), "test", &errorCollector{err: &mirakurun.StatusError{StatusCode: 503}}, ch, scrapeErrors, slog.Default())
+
+	assert.Equal(t, before+1, testutil.ToFloat64(counter))
+}
+
+func TestScrapeTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		offset  float64
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "no header", header: "", offset: 0.5, want: 0},
+		{name: "with offset", header: "10", offset: 0.5, want: 9500 * time.Millisecond},
+		{name: "offset larger than timeout", header: "0.3", offset: 0.5, want: 300 * time.Millisecond},
+		{name: "invalid", header: "ten", offset: 0.5, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.header != "" {
+				r.Header.Set(scrapeTimeoutHeader, tt.header)
+			}
+
+			got, err := scrapeTimeout(r, tt.offset)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMetricsHandler_ScrapeTimeout(t *testing.T) {
+	enabled := true
+	defer func(prev *bool) { enableScrapeCollector = prev }(enableScrapeCollector)
+	enableScrapeCollector = &enabled
+
+	client, err := mirakurun.NewClient("http://localhost:40772", 1)
+	require.NoError(t, err)
+
+	mirakurunCollector := &MirakurunCollector{
+		Collectors:   map[string]Collector{"slow": &slowCollector{}},
+		client:       client,
+		logger:       slog.Default(),
+		scrapeErrors: newScrapeErrorsTotal(),
+	}
+
+	setInstanceLabel(t, false)
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	r.Header.Set(scrapeTimeoutHeader, "0.7")
+	w := httptest.NewRecorder()
+
+	begin := time.Now()
+	MetricsHandler([]*MirakurunCollector{mirakurunCollector}, slog.Default())(w, r)
+
+	assert.Less(t, time.Since(begin), time.Second)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `mirakurun_scrape_collector_success{collector="slow"} 0`)
+	assert.Contains(t, w.Body.String(), `mirakurun_scrape_collector_errors_total{collector="slow",reason="timeout"}`)
+}
+
+func TestMetricsHandler_InvalidScrapeTimeout(t *testing.T) {
+	client, err := mirakurun.NewClient("http://localhost:40772", 1)
+	require.NoError(t, err)
+
+	mirakurunCollector := &MirakurunCollector{client: client, logger: slog.Default(), scrapeErrors: newScrapeErrorsTotal()}
+
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	r.Header.Set(scrapeTimeoutHeader, "invalid")
+	w := httptest.NewRecorder()
+
+	MetricsHandler([]*MirakurunCollector{mirakurunCollector}, slog.Default())(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestMetricsHandler_MultipleInstances(t *testing.T) {
+	enabled := true
+	defer func(prev *bool) { enableScrapeCollector = prev }(enableScrapeCollector)
+	enableScrapeCollector = &enabled
+	enableCollectors(t, "status")
+
+	healthy := mirakuruntest.NewServer(t, os.DirFS("../test/mirakurun"))
+	failing := mirakuruntest.NewServer(t, os.DirFS("../test/mirakurun"))
+	failing.SetFault("/api/status", fixture.Fault{StatusCode: http.StatusInternalServerError})
+
+	var mirakurunCollectors []*MirakurunCollector
+	for _, srv := range []*mirakuruntest.Server{healthy, failing} {
+		client, err := mirakurun.NewClient(srv.URL, 1)
+		require.NoError(t, err)
+		mirakurunCollector, err := NewMirakurunCollector(client, Settings{}, slog.Default())
+		require.NoError(t, err)
+		mirakurunCollectors = append(mirakurunCollectors, mirakurunCollector)
+	}
+
+	w := httptest.NewRecorder()
+	MetricsHandler(mirakurunCollectors, slog.Default())(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, fmt.Sprintf(`mirakurun_scrape_collector_success{collector="status",mirakurun_instance=%q} 1`, healthy.URL))
+	assert.Contains(t, body, fmt.Sprintf(`mirakurun_scrape_collector_success{collector="status",mirakurun_instance=%q} 0`, failing.URL))
+	assert.Contains(t, body, fmt.Sprintf(`mirakurun_scrape_collector_errors_total{collector="status",mirakurun_instance=%q,reason="http_status"} 1`, failing.URL))
+	assert.Contains(t, body, fmt.Sprintf(`mirakurun_status_version{mirakurun="4.0.0-beta.18",mirakurun_instance=%q,node="22.14.0"} 1`, healthy.URL))
+	assert.NotContains(t, body, fmt.Sprintf(`mirakurun_scrape_collector_errors_total{collector="status",mirakurun_instance=%q`, healthy.URL))
+}
+
+// setInstanceLabel sets --mirakurun.instance-label until the test ends.
+func setInstanceLabel(t *testing.T, enabled bool) {
+	t.Helper()
+	original := instanceLabelFlag
+	instanceLabelFlag = &enabled
+	t.Cleanup(func() { instanceLabelFlag = original })
+}
+
+func TestMetricsHandler_SingleInstanceLabel(t *testing.T) {
+	enableCollectors(t, "status")
+	srv := mirakuruntest.NewServer(t, os.DirFS("../test/mirakurun"))
+	client, err := mirakurun.NewClient(strings.Replace(srv.URL, "http://", "http://user:secret@", 1), 1)
+	require.NoError(t, err)
+	mirakurunCollector, err := NewMirakurunCollector(client, Settings{}, slog.Default())
+	require.NoError(t, err)
+
+	setInstanceLabel(t, true)
+	scrape := func() string {
+		w := httptest.NewRecorder()
+		MetricsHandler([]*MirakurunCollector{mirakurunCollector}, slog.Default())(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		return w.Body.String()
+	}
+
+	// The label is added to a single instance as well, without the credentials of the URL.
+	body := scrape()
+	assert.Contains(t, body, fmt.Sprintf(`mirakurun_status_version{mirakurun="4.0.0-beta.18",mirakurun_instance=%q,node="22.14.0"} 1`, srv.URL))
+	assert.NotContains(t, body, "secret")
+
+	setInstanceLabel(t, false)
+	body = scrape()
+	assert.Contains(t, body, `mirakurun_status_version{mirakurun="4.0.0-beta.18",node="22.14.0"} 1`)
+	assert.NotContains(t, body, instanceLabel)
+}
+
+func TestMetricsHandler_DuplicateInstances(t *testing.T) {
+	enableCollectors(t, "status")
+	srv := mirakuruntest.NewServer(t, os.DirFS("../test/mirakurun"))
+	var mirakurunCollectors []*MirakurunCollector
+	for _, user := range []string{"a", "b"} {
+		client, err := mirakurun.NewClient(strings.Replace(srv.URL, "http://", "http://"+user+":secret@", 1), 1)
+		require.NoError(t, err)
+		mirakurunCollector, err := NewMirakurunCollector(client, Settings{}, slog.Default())
+		require.NoError(t, err)
+		mirakurunCollectors = append(mirakurunCollectors, mirakurunCollector)
+	}
+
+	// Instances with the same label fail the scrape instead of panicking.
+	w := httptest.NewRecorder()
+	MetricsHandler(mirakurunCollectors, slog.Default())(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "failed to register the metrics of "+srv.URL)
+}
+
+func TestMetricsHandler_SelectCollectors(t *testing.T) {
+	enableCollectors(t, "status", "tuners", "version")
+	srv := mirakuruntest.NewServer(t, os.DirFS("../test/mirakurun"))
+	client, err := mirakurun.NewClient(srv.URL, 1)
+	require.NoError(t, err)
+	mirakurunCollector, err := NewMirakurunCollector(client, Settings{}, slog.Default())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		want     []string
+		notWant  []string
+	}{
+		{
+			name:     "正常系: 指定なし",
+			query:    "",
+			wantCode: http.StatusOK,
+			want:     []string{"mirakurun_status_", "mirakurun_tuners_", "mirakurun_version_"},
+		},
+		{
+			name:     "正常系: collect[]",
+			query:    "collect[]=tuners&collect[]=status",
+			wantCode: http.StatusOK,
+			want:     []string{"mirakurun_status_", "mirakurun_tuners_"},
+			notWant:  []string{"mirakurun_version_"},
+		},
+		{
+			name:     "正常系: exclude[]",
+			query:    "exclude[]=tuners&exclude[]=programs",
+			wantCode: http.StatusOK,
+			want:     []string{"mirakurun_status_", "mirakurun_version_"},
+			notWant:  []string{"mirakurun_tuners_"},
+		},
+		{
+			name:     "正常系: collect[] と exclude[]",
+			query:    "collect[]=tuners&collect[]=status&exclude[]=status",
+			wantCode: http.StatusOK,
+			want:     []string{"mirakurun_tuners_"},
+			notWant:  []string{"mirakurun_status_", "mirakurun_version_"},
+		},
+		{
+			name:     "エラー系: collect[] に存在しない collector",
+			query:    "collect[]=unknown",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "エラー系: collect[] に無効な collector",
+			query:    "collect[]=programs",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "エラー系: exclude[] に存在しない collector",
+			query:    "exclude[]=unknown",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			MetricsHandler([]*MirakurunCollector{mirakurunCollector}, slog.Default())(w, httptest.NewRequest(http.MethodGet, "/metrics?"+tt.query, nil))
+
+			require.Equal(t, tt.wantCode, w.Code, w.Body.String())
+			for _, prefix := range tt.want {
+				assert.Contains(t, w.Body.String(), prefix)
+			}
+			for _, prefix := range tt.notWant {
+				assert.NotContains(t, w.Body.String(), prefix)
+			}
+		})
+	}
+
+	// Selections are checked against the collectors being scraped, not the flags.
+	reloaded, err := NewMirakurunCollector(client, Settings{Enabled: map[string]bool{"tuners": false, "programs": true}}, slog.Default())
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	MetricsHandler([]*MirakurunCollector{reloaded}, slog.Default())(w, httptest.NewRequest(http.MethodGet, "/metrics?collect[]=tuners", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = httptest.NewRecorder()
+	MetricsHandler([]*MirakurunCollector{reloaded}, slog.Default())(w, httptest.NewRequest(http.MethodGet, "/metrics?collect[]=programs", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "mirakurun_programs_")
+}
+
+func TestMetricsHandler_ExporterGatherers(t *testing.T) {
+	enableCollectors(t, "status")
+	srv := mirakuruntest.NewServer(t, os.DirFS("../test/mirakurun"))
+	client, err := mirakurun.NewClient(srv.URL, 1)
+	require.NoError(t, err)
+	mirakurunCollector, err := NewMirakurunCollector(client, Settings{}, slog.Default())
+	require.NoError(t, err)
+
+	exporterRegistry := prometheus.NewRegistry()
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "mirakurun_exporter_test_total", Help: "Test metric"})
+	exporterRegistry.MustRegister(counter)
+	counter.Inc()
+
+	handler := MetricsHandler([]*MirakurunCollector{mirakurunCollector}, slog.Default(), exporterRegistry)
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/metrics?collect[]=status", nil))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "mirakurun_exporter_test_total 1")
+		assert.Contains(t, w.Body.String(), "mirakurun_status_version")
+	}
+}
@@ -0,0 +1,112 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/nasshu2916/mirakurun_exporter/mirakurun"
+)
+
+// collectorFeatures lists the Mirakurun features each collector needs.
+// Collectors that are not listed work with every supported version.
+var collectorFeatures = map[string]mirakurun.Feature{
+	"jobs": mirakurun.FeatureJobs,
+}
+
+var compatibilityModeDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "exporter", "compatibility_mode"),
+	"Compatibility mode selected from the Mirakurun version, mode is \"unknown\" when the version could not be detected",
+	[]string{"mode", "version"},
+	nil,
+)
+
+// versionRefreshInterval is how often the version of Mirakurun is detected again, e.g. to notice an upgrade.
+const versionRefreshInterval = 10 * time.Minute
+
+// versionCache keeps the detected version of Mirakurun, so that scrapes do not wait for /api/status
+// before running the collectors. The first scrape detects the version, later scrapes use the last
+// detected version and refresh it in the background after versionRefreshInterval or a failure.
+type versionCache struct {
+	mu         sync.Mutex
+	version    mirakurun.Version
+	detected   bool
+	checkedAt  time.Time
+	refreshing bool
+	now        func() time.Time
+	// refreshTimeout bounds a background detection, e.g. when the client has no request timeout.
+	refreshTimeout time.Duration
+}
+
+// MirakurunVersion returns the version of Mirakurun and whether it is known.
+func (s *source) MirakurunVersion(ctx context.Context, logger *slog.Logger) (mirakurun.Version, bool) {
+	cache := &s.versions
+	cache.mu.Lock()
+	if cache.checkedAt.IsZero() {
+		cache.mu.Unlock()
+		s.detectVersion(ctx, logger)
+		cache.mu.Lock()
+	} else if (!cache.detected || cache.now().Sub(cache.checkedAt) >= versionRefreshInterval) && !cache.refreshing {
+		cache.refreshing = true
+		go func() {
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cache.refreshTimeout)
+			defer cancel()
+			s.detectVersion(ctx, logger)
+			cache.mu.Lock()
+			cache.refreshing = false
+			cache.mu.Unlock()
+		}()
+	}
+	defer cache.mu.Unlock()
+	return cache.version, cache.detected
+}
+
+// detectVersion reads the version of Mirakurun from /api/status. A failure keeps the previously
+// detected version.
+func (s *source) detectVersion(ctx context.Context, logger *slog.Logger) {
+	version, err := s.fetchVersion(ctx, logger)
+
+	cache := &s.versions
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.checkedAt = cache.now()
+	if err != nil {
+		logger.Debug("failed to detect mirakurun version", "err", err)
+		return
+	}
+	cache.version = version
+	cache.detected = true
+}
+
+func (s *source) fetchVersion(ctx context.Context, logger *slog.Logger) (mirakurun.Version, error) {
+	status, err := s.GetStatus(ctx, logger)
+	if err != nil {
+		return mirakurun.Version{}, err
+	}
+	return mirakurun.ParseVersion(status.Version)
+}
+
+// compatibleCollectors returns the collectors supported by the Mirakurun version and reports the
+// compatibility mode to ch. When the version is unknown every collector is run.
+func (mirakurunCollector *MirakurunCollector) compatibleCollectors(ctx context.Context, ch chan<- prometheus.Metric) map[string]Collector {
+	logger := mirakurunCollector.logger
+	version, ok := mirakurunCollector.source.MirakurunVersion(ctx, logger)
+	if !ok {
+		ch <- prometheus.MustNewConstMetric(compatibilityModeDesc, prometheus.GaugeValue, 1, "unknown", "")
+		return mirakurunCollector.Collectors
+	}
+	ch <- prometheus.MustNewConstMetric(compatibilityModeDesc, prometheus.GaugeValue, 1, version.CompatibilityMode(), version.String())
+
+	collectors := make(map[string]Collector, len(mirakurunCollector.Collectors))
+	for name, c := range mirakurunCollector.Collectors {
+		if feature, ok := collectorFeatures[name]; ok && !version.Supports(feature) {
+			logger.Debug("skipping collector unsupported by mirakurun", "name", name, "version", version.String(), "feature", feature)
+			continue
+		}
+		collectors[name] = c
+	}
+	return collectors
+}
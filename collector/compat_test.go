@@ -0,0 +1,236 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nasshu2916/mirakurun_exporter/mirakurun"
+	"github.com/nasshu2916/mirakurun_exporter/mirakurun/fixture"
+	"github.com/nasshu2916/mirakurun_exporter/mirakuruntest"
+)
+
+func newFixtureDirClient(t *testing.T, dir string) (*mirakurun.Client, *mirakuruntest.Server) {
+	t.Helper()
+	srv := mirakuruntest.NewServer(t, os.DirFS(dir))
+	client, err := mirakurun.NewClient(srv.URL, 1)
+	require.NoError(t, err)
+	return client, srv
+}
+
+func TestMirakurunCollector_Compatibility(t *testing.T) {
+	original := *enableScrapeCollector
+	*enableScrapeCollector = true
+	defer func() { *enableScrapeCollector = original }()
+
+	tests := []struct {
+		name          string
+		fixtures      string
+		mode          string
+		version       string
+		jobs          bool
+		timerAccuracy bool
+		channels      int
+		tunerUsers    []string
+	}{
+		{
+			name:          "正常系: Mirakurun 4.x",
+			fixtures:      "../test/mirakurun",
+			mode:          "4.x",
+			version:       "4.0.0",
+			jobs:          true,
+			timerAccuracy: true,
+			channels:      3,
+			tunerUsers:    []string{""},
+		},
+		{
+			name:          "正常系: Mirakurun 3.x は jobs を収集せず、他の collector は収集する",
+			fixtures:      "../test/mirakurun/v3",
+			mode:          "3.x",
+			version:       "3.8.1",
+			jobs:          false,
+			timerAccuracy: false,
+			channels:      2,
+			tunerUsers:    []string{"EPGStation"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, srv := newFixtureDirClient(t, tt.fixtures)
+			source := newSource(client)
+			logger := slog.Default()
+			mirakurunCollector := &MirakurunCollector{
+				Collectors: map[string]Collector{
+					"status":  newStatusCollector(source, &collectorOptions{}, logger),
+					"jobs":    newJobsCollector(source, &collectorOptions{}, logger),
+					"channel": newChannelsCollector(source, &collectorOptions{}, logger),
+					"tuners":  newTunerCollector(source, &collectorOptions{}, logger),
+				},
+				client:       client,
+				source:       source,
+				logger:       logger,
+				scrapeErrors: newScrapeErrorsTotal(),
+			}
+
+			ch := make(chan prometheus.Metric, 200)
+			ok := mirakurunCollector.collect(context.Background(), ch)
+			close(ch)
+			assert.True(t, ok)
+
+			success := make(map[string]float64)
+			var timerAccuracy bool
+			var mode metricInfo
+			var channels int
+			var agents []string
+			for metric := range ch {
+				desc := metric.Desc().String()
+				switch {
+				case strings.Contains(desc, `"mirakurun_scrape_collector_success"`):
+					info := getMetricInfo(metric)
+					success[info.Labels["collector"]] = info.Value
+				case strings.Contains(desc, `"mirakurun_exporter_compatibility_mode"`):
+					mode = getMetricInfo(metric)
+				case strings.Contains(desc, "timer_accuracy"):
+					timerAccuracy = true
+				case strings.Contains(desc, `"mirakurun_channel_channel"`):
+					channels++
+				case strings.Contains(desc, `"mirakurun_tuners_users"`):
+					agents = append(agents, getMetricInfo(metric).Labels["agent"])
+				}
+			}
+
+			assert.Equal(t, map[string]string{"mode": tt.mode, "version": tt.version}, mode.Labels)
+			assert.Equal(t, 1.0, success["status"])
+			_, jobsCollected := success["jobs"]
+			assert.Equal(t, tt.jobs, jobsCollected)
+			assert.Equal(t, tt.jobs, srv.Requests("/api/jobs") > 0)
+			assert.Equal(t, tt.timerAccuracy, timerAccuracy)
+			assert.Equal(t, 1.0, success["channel"])
+			assert.Equal(t, tt.channels, channels)
+			assert.Equal(t, 1.0, success["tuners"])
+			assert.Equal(t, tt.tunerUsers, agents)
+		})
+	}
+}
+
+func TestMirakurunCollector_CompatibilityUnknown(t *testing.T) {
+	client, srv := newFixtureDirClient(t, t.TempDir())
+	source := newSource(client)
+	logger := slog.Default()
+	mirakurunCollector := &MirakurunCollector{
+		Collectors:   map[string]Collector{"jobs": newJobsCollector(source, &collectorOptions{}, logger)},
+		client:       client,
+		source:       source,
+		logger:       logger,
+		scrapeErrors: newScrapeErrorsTotal(),
+	}
+
+	ch := make(chan prometheus.Metric, 200)
+	mirakurunCollector.collect(context.Background(), ch)
+	close(ch)
+
+	var mode metricInfo
+	for metric := range ch {
+		if strings.Contains(metric.Desc().String(), `"mirakurun_exporter_compatibility_mode"`) {
+			mode = getMetricInfo(metric)
+		}
+	}
+
+	// The jobs collector still runs when the version is unknown.
+	assert.Equal(t, "unknown", mode.Labels["mode"])
+	assert.Equal(t, 1, srv.Requests("/api/jobs"))
+}
+
+func TestMirakurunCollector_CompatibilityCachesVersion(t *testing.T) {
+	client, srv := newFixtureDirClient(t, "../test/mirakurun")
+	source := newSource(client)
+	var mu sync.Mutex
+	now := time.Unix(1748000000, 0)
+	source.versions.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	logger := slog.Default()
+	mirakurunCollector := &MirakurunCollector{
+		Collectors:   map[string]Collector{"jobs": newJobsCollector(source, &collectorOptions{}, logger)},
+		client:       client,
+		source:       source,
+		logger:       logger,
+		scrapeErrors: newScrapeErrorsTotal(),
+	}
+	scrape := func() string {
+		ch := make(chan prometheus.Metric, 200)
+		mirakurunCollector.collect(context.Background(), ch)
+		close(ch)
+		for metric := range ch {
+			if strings.Contains(metric.Desc().String(), `"mirakurun_exporter_compatibility_mode"`) {
+				return getMetricInfo(metric).Labels["mode"]
+			}
+		}
+		return ""
+	}
+
+	for range 3 {
+		assert.Equal(t, "4.x", scrape())
+	}
+	assert.Equal(t, 1, srv.Requests("/api/status"))
+
+	// The version is detected again in the background once the interval has passed.
+	mu.Lock()
+	now = now.Add(versionRefreshInterval)
+	mu.Unlock()
+	assert.Equal(t, "4.x", scrape())
+	assert.Eventually(t, func() bool { return srv.Requests("/api/status") == 2 }, time.Second, 10*time.Millisecond)
+}
+
+func TestMirakurunCollector_CompatibilityRetriesAfterError(t *testing.T) {
+	client, srv := newFixtureDirClient(t, "../test/mirakurun")
+	srv.SetFault("/api/status", fixture.Fault{StatusCode: http.StatusInternalServerError})
+	source := newSource(client)
+	logger := slog.Default()
+
+	_, ok := source.MirakurunVersion(context.Background(), logger)
+	assert.False(t, ok)
+
+	// Scrapes after a failure do not wait for /api/status, but start detecting the version again.
+	srv.ClearFaults()
+	assert.Eventually(t, func() bool {
+		version, ok := source.MirakurunVersion(context.Background(), logger)
+		return ok && version.Major == 4
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestMirakurunCollector_CompatibilityRefreshTimeout(t *testing.T) {
+	srv := mirakuruntest.NewServer(t, os.DirFS("../test/mirakurun"))
+	// The http.Client has no timeout, so only the refresh timeout ends a hung detection.
+	client, err := mirakurun.NewClientWithOptions(srv.URL, mirakurun.WithHTTPClient(&http.Client{}))
+	require.NoError(t, err)
+	source := newSource(client)
+	source.versions.refreshTimeout = 50 * time.Millisecond
+	logger := slog.Default()
+
+	srv.SetFault("/api/status", fixture.Fault{StatusCode: http.StatusInternalServerError})
+	_, ok := source.MirakurunVersion(context.Background(), logger)
+	assert.False(t, ok)
+
+	// A hung detection gives up, so that a later scrape can start detecting the version again.
+	srv.SetFault("/api/status", fixture.Fault{Latency: time.Hour})
+	_, ok = source.MirakurunVersion(context.Background(), logger)
+	assert.False(t, ok)
+	assert.Eventually(t, func() bool {
+		source.versions.mu.Lock()
+		defer source.versions.mu.Unlock()
+		return !source.versions.refreshing
+	}, time.Second, 10*time.Millisecond)
+}
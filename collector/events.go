@@ -0,0 +1,145 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/nasshu2916/mirakurun_exporter/mirakurun"
+)
+
+type eventSubscriber interface {
+	SubscribeEvents(ctx context.Context, fn func(event *mirakurun.Event), logger *slog.Logger) error
+}
+
+// eventsCollector counts the events received from the Mirakurun event stream, which reveals
+// changes between scrapes such as tuners being assigned and released.
+type eventsCollector struct {
+	logger *slog.Logger
+
+	eventSubscriber eventSubscriber
+	// resources are the resources whose events are counted. Empty counts every resource.
+	resources []string
+
+	metrics     map[string]*prometheus.Desc
+	metricTypes map[string]prometheus.ValueType
+
+	mu         sync.Mutex
+	counts     map[eventKey]int64
+	lastEvents map[string]int64
+}
+
+type eventKey struct {
+	resource  string
+	eventType string
+}
+
+// mirakurunEventResources are the resources Mirakurun sends events of.
+var mirakurunEventResources = []string{"program", "service", "tuner"}
+
+var eventResources *[]string
+
+func init() {
+	registerCollector("events", defaultDisabled, newEventsCollector)
+	eventResources = kingpin.Flag("collector.events.resource", "Count only the events of RESOURCE, one of "+strings.Join(mirakurunEventResources, ", ")+". Can be repeated, all resources are counted without it.").
+		PlaceHolder("RESOURCE").
+		Enums(mirakurunEventResources...)
+}
+
+func newEventsCollector(source *source, options *collectorOptions, logger *slog.Logger) Collector {
+	const subsystem = "events"
+
+	metricDefs := map[string]metricDefinition{
+		"total": {
+			name:       "total",
+			help:       "Number of events received from the Mirakurun event stream",
+			labelNames: []string{"resource", "type"},
+			metricType: prometheus.CounterValue,
+		},
+		"last_timestamp_seconds": {
+			name:       "last_timestamp_seconds",
+			help:       "Time of the last event of a resource",
+			labelNames: []string{"resource"},
+			metricType: prometheus.GaugeValue,
+		},
+	}
+
+	metrics := make(map[string]*prometheus.Desc)
+	metricTypes := make(map[string]prometheus.ValueType)
+	for name, def := range metricDefs {
+		metrics[name] = prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, def.name),
+			def.help,
+			def.labelNames,
+			nil,
+		)
+		metricTypes[name] = def.metricType
+	}
+
+	return &eventsCollector{
+		eventSubscriber: source,
+		resources:       options.eventResources,
+		logger:          logger,
+		metrics:         metrics,
+		metricTypes:     metricTypes,
+		counts:          make(map[eventKey]int64),
+		lastEvents:      make(map[string]int64),
+	}
+}
+
+func (c *eventsCollector) Run(ctx context.Context) {
+	_ = c.eventSubscriber.SubscribeEvents(ctx, c.observe, c.logger)
+}
+
+func (c *eventsCollector) observe(event *mirakurun.Event) {
+	if len(c.resources) > 0 && !slices.Contains(c.resources, event.Resource) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.counts[eventKey{resource: event.Resource, eventType: event.Type}]++
+	if event.Time > c.lastEvents[event.Resource] {
+		c.lastEvents[event.Resource] = event.Time
+	}
+}
+
+func (c *eventsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range c.metrics {
+		ch <- desc
+	}
+}
+
+func (c *eventsCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric) error {
+	// Events are counted as they arrive from /api/events/stream. Send copies, so that the stream is not
+	// held up while a slow scrape takes the metrics.
+	c.mu.Lock()
+	counts := maps.Clone(c.counts)
+	lastEvents := maps.Clone(c.lastEvents)
+	c.mu.Unlock()
+
+	for key, count := range counts {
+		ch <- prometheus.MustNewConstMetric(
+			c.metrics["total"],
+			c.metricTypes["total"],
+			float64(count),
+			key.resource, key.eventType,
+		)
+	}
+	for resource, last := range lastEvents {
+		ch <- prometheus.MustNewConstMetric(
+			c.metrics["last_timestamp_seconds"],
+			c.metricTypes["last_timestamp_seconds"],
+			float64(last)/1000,
+			resource,
+		)
+	}
+	return nil
+}
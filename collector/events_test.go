@@ -0,0 +1,137 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nasshu2916/mirakurun_exporter/mirakurun"
+)
+
+type mockEventSubscriber struct {
+	events []mirakurun.Event
+	done   chan struct{}
+}
+
+func (m *mockEventSubscriber) SubscribeEvents(ctx context.Context, fn func(event *mirakurun.Event), logger *slog.Logger) error {
+	for i := range m.events {
+		fn(&m.events[i])
+	}
+	close(m.done)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestEventsCollector_Collect(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources []string
+		events    []mirakurun.Event
+		want      map[string]float64
+	}{
+		{
+			name: "正常系",
+			events: []mirakurun.Event{
+				{Resource: "tuner", Type: "update", Time: 1749000000000},
+				{Resource: "tuner", Type: "update", Time: 1749000002000},
+				{Resource: "tuner", Type: "update", Time: 1749000001000},
+				{Resource: "program", Type: "create", Time: 1749000003500},
+			},
+			want: map[string]float64{
+				"total/tuner/update":             3,
+				"total/program/create":           1,
+				"last_timestamp_seconds/tuner":   1749000002,
+				"last_timestamp_seconds/program": 1749000003.5,
+			},
+		},
+		{
+			name:      "正常系: resource を絞り込む",
+			resources: []string{"tuner"},
+			events: []mirakurun.Event{
+				{Resource: "tuner", Type: "update", Time: 1749000000000},
+				{Resource: "program", Type: "create", Time: 1749000003500},
+			},
+			want: map[string]float64{
+				"total/tuner/update":           1,
+				"last_timestamp_seconds/tuner": 1749000000,
+			},
+		},
+		{
+			name:   "正常系: イベントなし",
+			events: nil,
+			want:   map[string]float64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector := newEventsCollector(nil, &collectorOptions{eventResources: tt.resources}, slog.Default()).(*eventsCollector)
+			subscriber := &mockEventSubscriber{events: tt.events, done: make(chan struct{})}
+			collector.eventSubscriber = subscriber
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go collector.Run(ctx)
+
+			select {
+			case <-subscriber.done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for events")
+			}
+
+			ch := make(chan prometheus.Metric, 100)
+			err := collector.Collect(context.Background(), ch)
+			close(ch)
+			require.NoError(t, err)
+
+			got := make(map[string]float64)
+			for metric := range ch {
+				info := getMetricInfo(metric)
+				if resource, ok := info.Labels["resource"]; ok && info.Type == prometheus.CounterValue {
+					got["total/"+resource+"/"+info.Labels["type"]] = info.Value
+				} else {
+					got["last_timestamp_seconds/"+resource] = info.Value
+				}
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestEventsCollector_SlowScrapeDoesNotBlockStream(t *testing.T) {
+	collector := newEventsCollector(nil, &collectorOptions{}, slog.Default()).(*eventsCollector)
+	collector.observe(&mirakurun.Event{Resource: "tuner", Type: "update", Time: 1749000000000})
+
+	ch := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	go func() {
+		_ = collector.Collect(context.Background(), ch)
+		close(done)
+	}()
+	// The scrape has started and stalls on its next metric.
+	<-ch
+
+	observed := make(chan struct{})
+	go func() {
+		collector.observe(&mirakurun.Event{Resource: "tuner", Type: "update", Time: 1749000001000})
+		close(observed)
+	}()
+	select {
+	case <-observed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the stream is blocked by the scrape")
+	}
+
+	for {
+		select {
+		case <-ch:
+		case <-done:
+			return
+		}
+	}
+}
@@ -14,7 +14,6 @@ type jobsGetter interface {
 }
 
 type jobsCollector struct {
-	ctx    context.Context
 	logger *slog.Logger
 
 	jobsGetter jobsGetter
@@ -27,7 +26,7 @@ func init() {
 	registerCollector("jobs", defaultEnabled, newJobsCollector)
 }
 
-func newJobsCollector(ctx context.Context, client *mirakurun.Client, logger *slog.Logger) Collector {
+func newJobsCollector(source *source, options *collectorOptions, logger *slog.Logger) Collector {
 	const subsystem = "jobs"
 
 	metricDefs := map[string]metricDefinition{
@@ -77,8 +76,7 @@ func newJobsCollector(ctx context.Context, client *mirakurun.Client, logger *slo
 	}
 
 	return &jobsCollector{
-		ctx:         ctx,
-		jobsGetter:  client,
+		jobsGetter:  source,
 		logger:      logger,
 		metrics:     metrics,
 		metricTypes: metricTypes,
@@ -91,8 +89,8 @@ func (c *jobsCollector) Describe(ch chan<- *prometheus.Desc) {
 	}
 }
 
-func (c *jobsCollector) Collect(ch chan<- prometheus.Metric) error {
-	jobs, err := c.jobsGetter.GetJobs(c.ctx, c.logger)
+func (c *jobsCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric) error {
+	jobs, err := c.jobsGetter.GetJobs(ctx, c.logger)
 	if err != nil {
 		return err
 	}
@@ -132,11 +132,11 @@ func TestJobsCollector_Collect(t *testing.T) {
 				mock.err = assert.AnError
 			}
 
-			collector := newJobsCollector(context.Background(), nil, slog.Default())
+			collector := newJobsCollector(nil, &collectorOptions{}, slog.Default())
 			collector.(*jobsCollector).jobsGetter = mock
 
 			ch := make(chan prometheus.Metric, 100)
-			err := collector.Collect(ch)
+			err := collector.Collect(context.Background(), ch)
 			if tt.wantErr {
 				require.Error(t, err)
 				return
@@ -158,7 +158,7 @@ func TestJobsCollector_Collect(t *testing.T) {
 }
 
 func TestJobsCollector_Describe(t *testing.T) {
-	collector := newJobsCollector(context.Background(), nil, slog.Default())
+	collector := newJobsCollector(nil, &collectorOptions{}, slog.Default())
 	ch := make(chan *prometheus.Desc, 64)
 	collector.Describe(ch)
 
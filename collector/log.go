@@ -0,0 +1,189 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/nasshu2916/mirakurun_exporter/mirakurun"
+)
+
+// logLevels are the levels Mirakurun logs with. Their counters are exported from the start.
+var logLevels = []string{"debug", "info", "warning", "error", "fatal"}
+
+var logPatterns = &logPatternsValue{}
+
+func init() {
+	registerCollector("log", defaultDisabled, newLogCollector)
+	kingpin.Flag("collector.log.pattern", "Count log lines matching REGEX as pattern NAME in mirakurun_log_pattern_matches_total. Can be repeated.").
+		PlaceHolder("NAME=REGEX").
+		SetValue(logPatterns)
+}
+
+type logPattern struct {
+	name   string
+	regexp *regexp.Regexp
+}
+
+// logPatternsValue is a repeatable kingpin flag of NAME=REGEX pairs, compiled when parsed.
+type logPatternsValue []logPattern
+
+func (v *logPatternsValue) Set(value string) error {
+	name, expr, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected NAME=REGEX but got %q", value)
+	}
+	for _, pattern := range *v {
+		if pattern.name == name {
+			return fmt.Errorf("duplicate log pattern %q", name)
+		}
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return fmt.Errorf("invalid log pattern %q: %w", name, err)
+	}
+	*v = append(*v, logPattern{name: name, regexp: re})
+	return nil
+}
+
+func (v *logPatternsValue) String() string {
+	patterns := make([]string, 0, len(*v))
+	for _, pattern := range *v {
+		patterns = append(patterns, pattern.name+"="+pattern.regexp.String())
+	}
+	return strings.Join(patterns, ",")
+}
+
+func (v *logPatternsValue) IsCumulative() bool {
+	return true
+}
+
+type logSubscriber interface {
+	SubscribeLog(ctx context.Context, fn func(line mirakurun.LogLine), logger *slog.Logger) error
+}
+
+// logCollector counts the lines of the Mirakurun log stream by level and by the patterns
+// given with --collector.log.pattern.
+type logCollector struct {
+	logger *slog.Logger
+
+	logSubscriber logSubscriber
+	patterns      []logPattern
+
+	metrics     map[string]*prometheus.Desc
+	metricTypes map[string]prometheus.ValueType
+
+	mu      sync.Mutex
+	levels  map[string]int64
+	matches map[string]int64
+}
+
+func newLogCollector(source *source, options *collectorOptions, logger *slog.Logger) Collector {
+	const subsystem = "log"
+
+	metricDefs := map[string]metricDefinition{
+		"lines_total": {
+			name:       "lines_total",
+			help:       "Number of Mirakurun log lines by level",
+			labelNames: []string{"level"},
+			metricType: prometheus.CounterValue,
+		},
+		"pattern_matches_total": {
+			name:       "pattern_matches_total",
+			help:       "Number of Mirakurun log lines matching a pattern",
+			labelNames: []string{"pattern"},
+			metricType: prometheus.CounterValue,
+		},
+	}
+
+	metrics := make(map[string]*prometheus.Desc)
+	metricTypes := make(map[string]prometheus.ValueType)
+	for name, def := range metricDefs {
+		metrics[name] = prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, def.name),
+			def.help,
+			def.labelNames,
+			nil,
+		)
+		metricTypes[name] = def.metricType
+	}
+
+	levels := make(map[string]int64)
+	for _, level := range logLevels {
+		levels[level] = 0
+	}
+	matches := make(map[string]int64)
+	for _, pattern := range options.logPatterns {
+		matches[pattern.name] = 0
+	}
+
+	return &logCollector{
+		logSubscriber: source,
+		patterns:      options.logPatterns,
+		logger:        logger,
+		metrics:       metrics,
+		metricTypes:   metricTypes,
+		levels:        levels,
+		matches:       matches,
+	}
+}
+
+func (c *logCollector) Run(ctx context.Context) {
+	_ = c.logSubscriber.SubscribeLog(ctx, c.observe, c.logger)
+}
+
+// observe counts a log line. Continuation lines, such as those of a stack trace, have no level
+// and are only matched against the patterns.
+func (c *logCollector) observe(line mirakurun.LogLine) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if line.Level != "" {
+		c.levels[line.Level]++
+	}
+	for _, pattern := range c.patterns {
+		if pattern.regexp.MatchString(line.Raw) {
+			c.matches[pattern.name]++
+		}
+	}
+}
+
+func (c *logCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range c.metrics {
+		ch <- desc
+	}
+}
+
+func (c *logCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric) error {
+	// Every line read from /api/log/stream is matched under c.mu. Send copies, so that lines keep being
+	// counted while a slow scrape takes the metrics.
+	c.mu.Lock()
+	levels := maps.Clone(c.levels)
+	matches := maps.Clone(c.matches)
+	c.mu.Unlock()
+
+	for level, count := range levels {
+		ch <- prometheus.MustNewConstMetric(
+			c.metrics["lines_total"],
+			c.metricTypes["lines_total"],
+			float64(count),
+			level,
+		)
+	}
+	for name, count := range matches {
+		ch <- prometheus.MustNewConstMetric(
+			c.metrics["pattern_matches_total"],
+			c.metricTypes["pattern_matches_total"],
+			float64(count),
+			name,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nasshu2916/mirakurun_exporter/mirakurun"
+)
+
+type mockLogSubscriber struct {
+	lines []string
+	done  chan struct{}
+}
+
+func (m *mockLogSubscriber) SubscribeLog(ctx context.Context, fn func(line mirakurun.LogLine), logger *slog.Logger) error {
+	for _, line := range m.lines {
+		fn(mirakurun.ParseLogLine(line))
+	}
+	close(m.done)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestLogPatternsValue_Set(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		wantErr bool
+		want    string
+	}{
+		{
+			name:   "正常系",
+			values: []string{"tuner_exit=process has exited", "epg_error=EPG gathering .* failed"},
+			want:   "tuner_exit=process has exited,epg_error=EPG gathering .* failed",
+		},
+		{
+			name:   "正常系: 正規表現に=を含む",
+			values: []string{"exit_code=exit code=[1-9]"},
+			want:   "exit_code=exit code=[1-9]",
+		},
+		{
+			name:    "エラー系: 名前なし",
+			values:  []string{"=foo"},
+			wantErr: true,
+		},
+		{
+			name:    "エラー系: 不正な正規表現",
+			values:  []string{"bad=("},
+			wantErr: true,
+		},
+		{
+			name:    "エラー系: 名前の重複",
+			values:  []string{"exit=foo", "exit=bar"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var patterns logPatternsValue
+			var err error
+			for _, value := range tt.values {
+				if err = patterns.Set(value); err != nil {
+					break
+				}
+			}
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, patterns.String())
+		})
+	}
+}
+
+func TestLogCollector_Collect(t *testing.T) {
+	patterns := logPatternsValue{}
+	require.NoError(t, patterns.Set("tuner_exit=TunerDevice#\\d+ process has exited"))
+	require.NoError(t, patterns.Set("epg_error=EPG gathering .* has failed"))
+	require.NoError(t, patterns.Set("stack_trace=^\\s+at "))
+	require.NoError(t, patterns.Set("unused=never matches"))
+
+	collector := newLogCollector(nil, &collectorOptions{logPatterns: patterns}, slog.Default()).(*logCollector)
+	subscriber := &mockLogSubscriber{
+		lines: []string{
+			"2025-06-04T01:20:00.000Z info: 192.168.1.10:53833 -- GET /api/channels/GR/T27/stream?decode=1 -- 200 (Chrome)",
+			"2025-06-04T01:25:30.004Z warning: TunerDevice#1 process has exited with exit code=1",
+			"2025-06-04T01:25:40.004Z warning: TunerDevice#2 process has exited with exit code=1",
+			"2025-06-04T01:25:30.010Z error: EPG gathering network#32736 has failed [Error: stream has closed]",
+			"    at TSFilter._close (/app/lib/Mirakurun/TSFilter.js:715:19)",
+		},
+		done: make(chan struct{}),
+	}
+	collector.logSubscriber = subscriber
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go collector.Run(ctx)
+
+	select {
+	case <-subscriber.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for log lines")
+	}
+
+	ch := make(chan prometheus.Metric, 100)
+	err := collector.Collect(context.Background(), ch)
+	close(ch)
+	require.NoError(t, err)
+
+	levels := make(map[string]float64)
+	matches := make(map[string]float64)
+	for metric := range ch {
+		info := getMetricInfo(metric)
+		assert.Equal(t, prometheus.CounterValue, info.Type)
+		if level, ok := info.Labels["level"]; ok {
+			levels[level] = info.Value
+		} else {
+			matches[info.Labels["pattern"]] = info.Value
+		}
+	}
+
+	assert.Equal(t, map[string]float64{"debug": 0, "info": 1, "warning": 2, "error": 1, "fatal": 0}, levels)
+	assert.Equal(t, map[string]float64{"tuner_exit": 2, "epg_error": 1, "stack_trace": 1, "unused": 0}, matches)
+}
+
+func TestLogCollector_SlowScrapeDoesNotBlockStream(t *testing.T) {
+	collector := newLogCollector(nil, &collectorOptions{}, slog.Default()).(*logCollector)
+
+	ch := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	go func() {
+		_ = collector.Collect(context.Background(), ch)
+		close(done)
+	}()
+	// The scrape has started and stalls on its next metric.
+	<-ch
+
+	observed := make(chan struct{})
+	go func() {
+		collector.observe(mirakurun.LogLine{Level: "info", Message: "GET /api/status"})
+		close(observed)
+	}()
+	select {
+	case <-observed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the stream is blocked by the scrape")
+	}
+
+	for {
+		select {
+		case <-ch:
+		case <-done:
+			return
+		}
+	}
+}
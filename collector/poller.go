@@ -0,0 +1,209 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	lastPollTimestampDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "exporter", "last_poll_timestamp_seconds"),
+		"mirakurun_exporter: Unix time when the last background poll of Mirakurun completed",
+		nil,
+		nil,
+	)
+	lastPollSuccessDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "exporter", "last_poll_success"),
+		"mirakurun_exporter: Whether all collectors succeeded in the last background poll",
+		nil,
+		nil,
+	)
+	lastPollDurationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "exporter", "last_poll_duration_seconds"),
+		"mirakurun_exporter: Duration of the last background poll of Mirakurun",
+		nil,
+		nil,
+	)
+)
+
+// Poller collects metrics from Mirakurun on its own schedule.
+// Scrapes are served from the last completed poll and never reach Mirakurun.
+type Poller struct {
+	collector *MirakurunCollector
+	interval  time.Duration
+	logger    *slog.Logger
+
+	mu sync.RWMutex
+	// metrics are the metrics of the last poll by collector name, with those of no single collector under "".
+	metrics  map[string][]prometheus.Metric
+	lastPoll time.Time
+	success  bool
+	duration time.Duration
+}
+
+func NewPoller(collector *MirakurunCollector, interval time.Duration, logger *slog.Logger) *Poller {
+	return &Poller{
+		collector: collector,
+		interval:  interval,
+		logger:    logger,
+	}
+}
+
+// Run polls immediately and then every interval until ctx is done.
+// Each poll must complete within the interval.
+func (p *Poller) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Poller) poll(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, p.interval)
+	defer cancel()
+
+	begin := time.Now()
+	channels := make(map[string]chan prometheus.Metric, len(p.collector.Collectors)+1)
+	for _, name := range append(slices.Collect(maps.Keys(p.collector.Collectors)), "") {
+		channels[name] = make(chan prometheus.Metric)
+	}
+	var (
+		metricsMu sync.Mutex
+		count     int
+		wg        sync.WaitGroup
+	)
+	metrics := make(map[string][]prometheus.Metric, len(channels))
+	for name, ch := range channels {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var collected []prometheus.Metric
+			for metric := range ch {
+				collected = append(collected, metric)
+			}
+			metricsMu.Lock()
+			metrics[name] = collected
+			count += len(collected)
+			metricsMu.Unlock()
+		}()
+	}
+	success := p.collector.collectEach(ctx, func(name string) chan<- prometheus.Metric { return channels[name] })
+	for _, ch := range channels {
+		close(ch)
+	}
+	wg.Wait()
+	duration := time.Since(begin)
+
+	p.mu.Lock()
+	p.metrics = metrics
+	p.lastPoll = time.Now()
+	p.success = success
+	p.duration = duration
+	p.mu.Unlock()
+
+	p.logger.Debug("poll completed", "success", success, "duration_seconds", duration.Seconds(), "metrics", count)
+}
+
+func (p *Poller) Describe(ch chan<- *prometheus.Desc) {
+	p.collector.Describe(ch)
+	ch <- lastPollTimestampDesc
+	ch <- lastPollSuccessDesc
+	ch <- lastPollDurationDesc
+}
+
+// Collect replays the metrics of the last completed poll.
+func (p *Poller) Collect(ch chan<- prometheus.Metric) {
+	p.collect(ch, nil)
+}
+
+// collect replays the metrics of the last completed poll that belong to the named collectors, or to
+// any collector when names is nil. Metrics of no single collector are always replayed.
+func (p *Poller) collect(ch chan<- prometheus.Metric, names []string) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for name, metrics := range p.metrics {
+		if names != nil && name != "" && !slices.Contains(names, name) {
+			continue
+		}
+		for _, metric := range metrics {
+			ch <- metric
+		}
+	}
+
+	var lastPoll float64
+	if !p.lastPoll.IsZero() {
+		lastPoll = float64(p.lastPoll.UnixNano()) / 1e9
+	}
+	ch <- prometheus.MustNewConstMetric(lastPollTimestampDesc, prometheus.GaugeValue, lastPoll)
+	ch <- prometheus.MustNewConstMetric(lastPollSuccessDesc, prometheus.GaugeValue, boolToFloat64(p.success))
+	ch <- prometheus.MustNewConstMetric(lastPollDurationDesc, prometheus.GaugeValue, p.duration.Seconds())
+}
+
+// only returns a view of p serving the metrics of the named collectors, or of all collectors when names is nil.
+func (p *Poller) only(names []string) prometheus.Collector {
+	if names == nil {
+		return p
+	}
+	return &pollerSubset{poller: p, names: names}
+}
+
+// pollerSubset serves the metrics of some collectors of a Poller.
+type pollerSubset struct {
+	poller *Poller
+	names  []string
+}
+
+func (s *pollerSubset) Describe(ch chan<- *prometheus.Desc) {
+	s.poller.Describe(ch)
+}
+
+func (s *pollerSubset) Collect(ch chan<- prometheus.Metric) {
+	s.poller.collect(ch, s.names)
+}
+
+// PollerHandler serves the metrics of the last completed poll of pollers, one per Mirakurun instance,
+// and those of exporterGatherers. Polls run every collector; collect[] and exclude[] select which are served.
+func PollerHandler(pollers []*Poller, logger *slog.Logger, exporterGatherers ...prometheus.Gatherer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger.Debug("metrics request", "url", r.URL.String())
+
+		names := make([][]string, len(pollers))
+		for i, poller := range pollers {
+			var err error
+			if names[i], err = selectCollectors(r.URL.Query(), poller.collector.Collectors); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		registry := prometheus.NewRegistry()
+		for i, poller := range pollers {
+			if err := registerInstance(registry, poller.collector, len(pollers) > 1, poller.only(names[i]), poller.collector.client); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		h := promhttp.HandlerFor(append(prometheus.Gatherers{registry}, exporterGatherers...), promhttp.HandlerOpts{
+			ErrorLog:      slog.NewLogLogger(logger.Handler(), slog.LevelError),
+			ErrorHandling: promhttp.ContinueOnError,
+		})
+		h.ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,167 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nasshu2916/mirakurun_exporter/mirakurun"
+)
+
+var pollTestDesc = prometheus.NewDesc("mirakurun_poll_test", "Test metric", nil, nil)
+
+type countingCollector struct {
+	calls atomic.Int32
+}
+
+func (c *countingCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- pollTestDesc
+}
+
+func (c *countingCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric) error {
+	ch <- prometheus.MustNewConstMetric(pollTestDesc, prometheus.GaugeValue, float64(c.calls.Add(1)))
+	return nil
+}
+
+func newPollerTestCollector(t *testing.T, collectors map[string]Collector) *MirakurunCollector {
+	t.Helper()
+	setInstanceLabel(t, false)
+
+	client, err := mirakurun.NewClient("http://localhost:40772", 1)
+	require.NoError(t, err)
+
+	return &MirakurunCollector{Collectors: collectors, client: client, logger: slog.Default(), scrapeErrors: newScrapeErrorsTotal()}
+}
+
+func TestPoller_ServesLastPoll(t *testing.T) {
+	counting := &countingCollector{}
+	poller := NewPoller(newPollerTestCollector(t, map[string]Collector{"counting": counting}), time.Minute, slog.Default())
+
+	poller.poll(context.Background())
+
+	w := httptest.NewRecorder()
+	PollerHandler([]*Poller{poller}, slog.Default())(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	w2 := httptest.NewRecorder()
+	PollerHandler([]*Poller{poller}, slog.Default())(w2, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	// Scrapes do not run the collectors.
+	assert.Equal(t, int32(1), counting.calls.Load())
+	assert.Contains(t, w.Body.String(), "mirakurun_poll_test 1")
+	assert.Contains(t, w.Body.String(), "mirakurun_exporter_last_poll_success 1")
+	assert.NotContains(t, w.Body.String(), "mirakurun_exporter_last_poll_timestamp_seconds 0\n")
+	assert.Equal(t, w.Body.String(), w2.Body.String())
+
+	poller.poll(context.Background())
+
+	w3 := httptest.NewRecorder()
+	PollerHandler([]*Poller{poller}, slog.Default())(w3, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, w3.Body.String(), "mirakurun_poll_test 2")
+
+}
+
+func TestPoller_SelectCollectors(t *testing.T) {
+	enabled := true
+	defer func(prev *bool) { enableScrapeCollector = prev }(enableScrapeCollector)
+	enableScrapeCollector = &enabled
+	poller := NewPoller(newPollerTestCollector(t, map[string]Collector{
+		"counting": &countingCollector{},
+		"error":    &errorCollector{err: assert.AnError},
+	}), time.Minute, slog.Default())
+	poller.poll(context.Background())
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		want     []string
+		notWant  []string
+	}{
+		{
+			name:     "正常系: collect[]",
+			query:    "collect[]=counting",
+			wantCode: http.StatusOK,
+			want:     []string{"mirakurun_poll_test 1", `mirakurun_scrape_collector_success{collector="counting"} 1`, "mirakurun_exporter_last_poll_success 0"},
+			notWant:  []string{`mirakurun_scrape_collector_success{collector="error"}`},
+		},
+		{
+			name:     "正常系: exclude[]",
+			query:    "exclude[]=counting",
+			wantCode: http.StatusOK,
+			want:     []string{`mirakurun_scrape_collector_success{collector="error"} 0`},
+			notWant:  []string{"mirakurun_poll_test", `mirakurun_scrape_collector_success{collector="counting"}`},
+		},
+		{
+			name:     "エラー系: collect[] に polling していない collector",
+			query:    "collect[]=status",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "エラー系: exclude[] に存在しない collector",
+			query:    "exclude[]=unknown",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			PollerHandler([]*Poller{poller}, slog.Default())(w, httptest.NewRequest(http.MethodGet, "/metrics?"+tt.query, nil))
+
+			require.Equal(t, tt.wantCode, w.Code, w.Body.String())
+			for _, s := range tt.want {
+				assert.Contains(t, w.Body.String(), s)
+			}
+			for _, s := range tt.notWant {
+				assert.NotContains(t, w.Body.String(), s)
+			}
+		})
+	}
+}
+
+func TestPoller_Failure(t *testing.T) {
+	poller := NewPoller(newPollerTestCollector(t, map[string]Collector{
+		"counting": &countingCollector{},
+		"error":    &errorCollector{err: assert.AnError},
+	}), time.Minute, slog.Default())
+
+	w := httptest.NewRecorder()
+	PollerHandler([]*Poller{poller}, slog.Default())(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, w.Body.String(), "mirakurun_exporter_last_poll_timestamp_seconds 0")
+	assert.Contains(t, w.Body.String(), "mirakurun_exporter_last_poll_success 0")
+
+	poller.poll(context.Background())
+
+	w = httptest.NewRecorder()
+	PollerHandler([]*Poller{poller}, slog.Default())(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, w.Body.String(), "mirakurun_exporter_last_poll_success 0")
+	assert.Contains(t, w.Body.String(), "mirakurun_poll_test 1")
+}
+
+func TestPoller_Run(t *testing.T) {
+	counting := &countingCollector{}
+	poller := NewPoller(newPollerTestCollector(t, map[string]Collector{"counting": counting}), 20*time.Millisecond, slog.Default())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		poller.Run(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool { return counting.calls.Load() >= 3 }, time.Second, 10*time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("poller did not stop")
+	}
+}
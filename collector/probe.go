@@ -0,0 +1,471 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/nasshu2916/mirakurun_exporter/mirakurun"
+)
+
+var (
+	probeSuccessDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "probe", "success"),
+		"mirakurun_exporter: Whether all collectors of the probe succeeded",
+		nil,
+		nil,
+	)
+	probeDurationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "probe", "duration_seconds"),
+		"mirakurun_exporter: Duration of the probe",
+		nil,
+		nil,
+	)
+)
+
+// ProbeModule selects the collectors run by a probe and bounds its duration.
+type ProbeModule struct {
+	// Collectors are the names of the collectors to run. Empty runs all enabled collectors.
+	Collectors []string
+	// Timeout bounds the probe in addition to the scrape timeout. Zero means no bound.
+	Timeout time.Duration
+}
+
+// ProbeModules is a repeatable kingpin flag of NAME=COLLECTOR[,COLLECTOR...][:TIMEOUT] modules,
+// e.g. "fast=tuners,status:5s".
+type ProbeModules map[string]ProbeModule
+
+func (v ProbeModules) Set(value string) error {
+	name, spec, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected NAME=COLLECTOR[,COLLECTOR...][:TIMEOUT] but got %q", value)
+	}
+	if _, ok := v[name]; ok {
+		return fmt.Errorf("duplicate probe module %q", name)
+	}
+
+	var module ProbeModule
+	collectors, timeout, hasTimeout := strings.Cut(spec, ":")
+	if hasTimeout {
+		d, err := time.ParseDuration(timeout)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid timeout of probe module %q: %q", name, timeout)
+		}
+		module.Timeout = d
+	}
+	for _, c := range strings.Split(collectors, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			module.Collectors = append(module.Collectors, c)
+		}
+	}
+	v[name] = module
+	return nil
+}
+
+func (v ProbeModules) String() string {
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	modules := make([]string, 0, len(v))
+	for _, name := range names {
+		module := name + "=" + strings.Join(v[name].Collectors, ",")
+		if v[name].Timeout > 0 {
+			module += ":" + v[name].Timeout.String()
+		}
+		modules = append(modules, module)
+	}
+	return strings.Join(modules, " ")
+}
+
+func (v ProbeModules) IsCumulative() bool {
+	return true
+}
+
+// ProbeTargetLimits bound the clients and collectors a Prober keeps for the targets it probed.
+type ProbeTargetLimits struct {
+	// MaxTargets is the number of targets kept. Beyond it the least recently probed target is dropped.
+	// Zero means no limit.
+	MaxTargets int
+	// IdleTimeout drops a target that was not probed for this long. Zero keeps idle targets.
+	IdleTimeout time.Duration
+}
+
+// Prober runs the enabled collectors against Mirakurun instances given per request.
+// The client and collectors of a target are created on its first probe and reused until the
+// target is dropped by the ProbeTargetLimits, which stops its background collectors.
+type Prober struct {
+	newClient      func(target string) (*mirakurun.Client, error)
+	allowedTargets []*targetPattern
+	allowAny       bool
+	modules        ProbeModules
+	limits         ProbeTargetLimits
+	settings       Settings
+	logger         *slog.Logger
+	ctx            context.Context
+	cancel         context.CancelFunc
+	now            func() time.Time
+
+	mu      sync.Mutex
+	targets map[string]*probeTarget
+}
+
+// probeTarget is a pooled collector and what is needed to drop it.
+type probeTarget struct {
+	collector *MirakurunCollector
+	ctx       context.Context
+	cancel    context.CancelFunc
+	lastProbe time.Time
+}
+
+// NewProber returns a Prober creating the client of a target with newClient. Only targets
+// matching one of allowedTargets, as URLs or patterns such as "http://192.168.1.*:40772", can be
+// probed, and "*" allows any target. The collectors of the targets follow settings, and every
+// collector of modules must be enabled.
+func NewProber(newClient func(target string) (*mirakurun.Client, error), allowedTargets []string, modules ProbeModules, limits ProbeTargetLimits, settings Settings, logger *slog.Logger) (*Prober, error) {
+	patterns := make([]*targetPattern, 0, len(allowedTargets))
+	allowAny := false
+	for _, allowed := range allowedTargets {
+		if allowed == "*" {
+			allowAny = true
+			continue
+		}
+		pattern, err := parseTargetPattern(allowed)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed target %q: %w", allowed, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	options, err := settings.options()
+	if err != nil {
+		return nil, err
+	}
+	for name, module := range modules {
+		for _, c := range module.Collectors {
+			if !options.enabled[c] {
+				return nil, fmt.Errorf("probe module %q: collector %q is unknown or disabled", name, c)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Prober{
+		newClient:      newClient,
+		allowedTargets: patterns,
+		allowAny:       allowAny,
+		modules:        modules,
+		limits:         limits,
+		settings:       settings,
+		logger:         logger,
+		ctx:            ctx,
+		cancel:         cancel,
+		now:            time.Now,
+		targets:        make(map[string]*probeTarget),
+	}
+	if limits.IdleTimeout > 0 {
+		go p.dropIdleTargets(limits.IdleTimeout)
+	}
+	return p, nil
+}
+
+// Close stops the background collectors of the probed targets.
+func (p *Prober) Close() {
+	p.cancel()
+}
+
+// parseTarget parses a target and normalizes it into its key in the pool, so that e.g. "http://h:40772"
+// and "HTTP://h:40772/" share one client. Credentials, queries and fragments are rejected, as they
+// could make a target look like an allowed one.
+func parseTarget(target string) (*url.URL, error) {
+	u, err := url.Parse(target)
+	switch {
+	case err != nil:
+		// The error of url.Parse quotes the target, which may carry credentials.
+		return nil, fmt.Errorf("not a URL")
+	case u.Scheme == "" || u.Opaque != "":
+		return nil, fmt.Errorf("not an absolute URL")
+	case u.User != nil:
+		return nil, fmt.Errorf("credentials are not allowed")
+	case u.RawQuery != "" || u.ForceQuery:
+		return nil, fmt.Errorf("a query is not allowed")
+	case u.Fragment != "" || strings.Contains(target, "#"):
+		return nil, fmt.Errorf("a fragment is not allowed")
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = ""
+	return u, nil
+}
+
+// targetPattern is an allowed target. Its scheme, host, port and path are matched separately, the
+// host label by label, so that e.g. "*" in "192.168.1.*" cannot match "5.attacker.example".
+type targetPattern struct {
+	scheme string
+	host   string
+	port   string
+	path   string
+}
+
+// parseTargetPattern parses SCHEME://HOST[:PORT][/PATH], where the host, port and path may be
+// path.Match patterns.
+func parseTargetPattern(pattern string) (*targetPattern, error) {
+	scheme, rest, ok := strings.Cut(pattern, "://")
+	if !ok || scheme == "" {
+		return nil, fmt.Errorf("expected SCHEME://HOST[:PORT][/PATH]")
+	}
+	authority, targetPath, _ := strings.Cut(rest, "/")
+	if strings.ContainsAny(authority, "@?#") || strings.ContainsAny(targetPath, "?#") {
+		return nil, fmt.Errorf("credentials, queries and fragments are not allowed")
+	}
+	host, port := splitHostPort(authority)
+	t := &targetPattern{
+		scheme: strings.ToLower(scheme),
+		host:   strings.ToLower(host),
+		port:   port,
+		path:   strings.TrimRight("/"+targetPath, "/"),
+	}
+	for _, part := range []string{t.host, t.port, t.path} {
+		if _, err := path.Match(part, ""); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// splitHostPort splits the authority of a URL into its host, without the brackets of an IPv6
+// address, and its port, which is empty when there is none.
+func splitHostPort(authority string) (string, string) {
+	host, port := authority, ""
+	if i := strings.LastIndex(authority, ":"); i >= 0 && !strings.Contains(authority[i:], "]") {
+		host, port = authority[:i], authority[i+1:]
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(host, "["), "]"), port
+}
+
+// defaultPort returns the port of scheme used when a URL has none.
+func defaultPort(scheme string) string {
+	switch scheme {
+	case "http":
+		return "80"
+	case "https":
+		return "443"
+	}
+	return ""
+}
+
+func (t *targetPattern) match(u *url.URL) bool {
+	if t.scheme != u.Scheme {
+		return false
+	}
+	patternPort, port := t.port, u.Port()
+	if patternPort == "" {
+		patternPort = defaultPort(t.scheme)
+	}
+	if port == "" {
+		port = defaultPort(u.Scheme)
+	}
+	if ok, _ := path.Match(patternPort, port); !ok {
+		return false
+	}
+	if ok, _ := path.Match(t.path, u.Path); !ok {
+		return false
+	}
+
+	patternLabels, labels := strings.Split(t.host, "."), strings.Split(u.Hostname(), ".")
+	if len(patternLabels) != len(labels) {
+		return false
+	}
+	for i, label := range labels {
+		if ok, _ := path.Match(patternLabels[i], label); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *Prober) allowed(target *url.URL) bool {
+	if p.allowAny {
+		return true
+	}
+	for _, pattern := range p.allowedTargets {
+		if pattern.match(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// collector returns the pooled collector of target, creating and starting it on first use.
+func (p *Prober) collector(target string) (*MirakurunCollector, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	if t, ok := p.targets[target]; ok {
+		t.lastProbe = now
+		return t.collector, nil
+	}
+
+	client, err := p.newClient(target)
+	if err != nil {
+		return nil, err
+	}
+	logger := p.logger.With("target", client.URL)
+	c, err := NewMirakurunCollector(client, p.settings, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.limits.MaxTargets > 0 {
+		for len(p.targets) >= p.limits.MaxTargets {
+			p.dropLeastRecentlyProbed()
+		}
+	}
+	ctx, cancel := context.WithCancel(p.ctx)
+	c.Start(ctx)
+	p.targets[target] = &probeTarget{collector: c, ctx: ctx, cancel: cancel, lastProbe: now}
+	logger.Info("probing new target")
+	return c, nil
+}
+
+// drop stops the background collectors of target and removes it from the pool. p.mu must be held.
+func (p *Prober) drop(target string, reason string) {
+	p.targets[target].cancel()
+	delete(p.targets, target)
+	p.logger.Info("dropped probe target", "target", mirakurun.RedactURL(target), "reason", reason)
+}
+
+func (p *Prober) dropLeastRecentlyProbed() {
+	var oldest string
+	for target, t := range p.targets {
+		if oldest == "" || t.lastProbe.Before(p.targets[oldest].lastProbe) {
+			oldest = target
+		}
+	}
+	p.drop(oldest, "max targets")
+}
+
+// dropIdle drops the targets not probed for longer than timeout.
+func (p *Prober) dropIdle(timeout time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	for target, t := range p.targets {
+		if now.Sub(t.lastProbe) >= timeout {
+			p.drop(target, "idle")
+		}
+	}
+}
+
+// dropIdleTargets checks for idle targets every timeout until the Prober is closed.
+func (p *Prober) dropIdleTargets(timeout time.Duration) {
+	ticker := time.NewTicker(timeout)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+			p.dropIdle(timeout)
+		}
+	}
+}
+
+// ProbeHandler serves the metrics of the Mirakurun given by the target query parameter, running
+// the collectors of the module given by the module parameter.
+func ProbeHandler(prober *Prober, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger.Debug("probe request", "url", r.URL.String())
+
+		query := r.URL.Query()
+		if query.Get("target") == "" {
+			http.Error(w, "target parameter is missing", http.StatusBadRequest)
+			return
+		}
+		u, err := parseTarget(query.Get("target"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid target: %v", err), http.StatusBadRequest)
+			return
+		}
+		target := u.String()
+		if !prober.allowed(u) {
+			http.Error(w, fmt.Sprintf("target %q is not allowed", target), http.StatusForbidden)
+			return
+		}
+
+		var module ProbeModule
+		if name := query.Get("module"); name != "" {
+			var ok bool
+			if module, ok = prober.modules[name]; !ok {
+				http.Error(w, fmt.Sprintf("unknown module %q", name), http.StatusBadRequest)
+				return
+			}
+		}
+
+		mirakurunCollector, err := prober.collector(target)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid target %q: %v", mirakurun.RedactURL(target), err), http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		timeout, err := scrapeTimeout(r, *scrapeTimeoutOffset)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if module.Timeout > 0 && (timeout == 0 || module.Timeout < timeout) {
+			timeout = module.Timeout
+		}
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(
+			&probeCollector{ctx: ctx, collector: mirakurunCollector.only(module.Collectors)},
+			mirakurunCollector.client,
+		)
+
+		h := promhttp.HandlerFor(registry, promhttp.HandlerOpts{
+			ErrorLog:      slog.NewLogLogger(logger.Handler(), slog.LevelError),
+			ErrorHandling: promhttp.ContinueOnError,
+		})
+		h.ServeHTTP(w, r)
+	}
+}
+
+// probeCollector binds a MirakurunCollector to the context of a single probe and reports its outcome.
+type probeCollector struct {
+	ctx       context.Context
+	collector *MirakurunCollector
+}
+
+func (p *probeCollector) Describe(ch chan<- *prometheus.Desc) {
+	p.collector.Describe(ch)
+	ch <- probeSuccessDesc
+	ch <- probeDurationDesc
+}
+
+func (p *probeCollector) Collect(ch chan<- prometheus.Metric) {
+	begin := time.Now()
+	success := p.collector.collect(p.ctx, ch)
+	ch <- prometheus.MustNewConstMetric(probeSuccessDesc, prometheus.GaugeValue, boolToFloat64(success))
+	ch <- prometheus.MustNewConstMetric(probeDurationDesc, prometheus.GaugeValue, time.Since(begin).Seconds())
+}
@@ -10,15 +10,14 @@ import (
 	"github.com/nasshu2916/mirakurun_exporter/mirakurun"
 )
 
-type programsGetter interface {
-	GetPrograms(ctx context.Context, logger *slog.Logger) (*mirakurun.ProgramsResponse, error)
+type programCountsGetter interface {
+	GetProgramCounts(ctx context.Context, logger *slog.Logger) (*mirakurun.ProgramCounts, error)
 }
 
 type programsCollector struct {
-	ctx    context.Context
 	logger *slog.Logger
 
-	programsGetter programsGetter
+	programCountsGetter programCountsGetter
 
 	metrics     map[string]*prometheus.Desc
 	metricTypes map[string]prometheus.ValueType
@@ -28,7 +27,7 @@ func init() {
 	registerCollector("programs", defaultEnabled, newProgramsCollector)
 }
 
-func newProgramsCollector(ctx context.Context, client *mirakurun.Client, logger *slog.Logger) Collector {
+func newProgramsCollector(source *source, options *collectorOptions, logger *slog.Logger) Collector {
 	const subsystem = "programs"
 
 	metricDefs := map[string]metricDefinition{
@@ -53,11 +52,10 @@ func newProgramsCollector(ctx context.Context, client *mirakurun.Client, logger
 	}
 
 	return &programsCollector{
-		ctx:            ctx,
-		programsGetter: client,
-		logger:         logger,
-		metrics:        metrics,
-		metricTypes:    metricTypes,
+		programCountsGetter: source,
+		logger:              logger,
+		metrics:             metrics,
+		metricTypes:         metricTypes,
 	}
 }
 
@@ -67,18 +65,13 @@ func (c *programsCollector) Describe(ch chan<- *prometheus.Desc) {
 	}
 }
 
-func (c *programsCollector) Collect(ch chan<- prometheus.Metric) error {
-	programs, err := c.programsGetter.GetPrograms(c.ctx, c.logger)
+func (c *programsCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric) error {
+	programCounts, err := c.programCountsGetter.GetProgramCounts(ctx, c.logger)
 	if err != nil {
 		return err
 	}
 
-	programCount := make(map[int]int)
-	for _, program := range *programs {
-		programCount[program.ServiceID]++
-	}
-
-	for serviceID, count := range programCount {
+	for serviceID, count := range *programCounts {
 		ch <- prometheus.MustNewConstMetric(
 			c.metrics["count"],
 			c.metricTypes["count"],
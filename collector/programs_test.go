@@ -13,34 +13,27 @@ import (
 	"github.com/nasshu2916/mirakurun_exporter/mirakurun"
 )
 
-type mockProgramsGetter struct {
-	programs *mirakurun.ProgramsResponse
-	err      error
+type mockProgramCountsGetter struct {
+	programCounts *mirakurun.ProgramCounts
+	err           error
 }
 
-func (m *mockProgramsGetter) GetPrograms(ctx context.Context, logger *slog.Logger) (*mirakurun.ProgramsResponse, error) {
-	return m.programs, m.err
+func (m *mockProgramCountsGetter) GetProgramCounts(ctx context.Context, logger *slog.Logger) (*mirakurun.ProgramCounts, error) {
+	return m.programCounts, m.err
 }
 
 func TestProgramsCollector_Collect(t *testing.T) {
 	tests := []struct {
-		name     string
-		programs *mirakurun.ProgramsResponse
-		wantErr  bool
-		checks   func(t *testing.T, metrics []prometheus.Metric)
+		name          string
+		programCounts *mirakurun.ProgramCounts
+		wantErr       bool
+		checks        func(t *testing.T, metrics []prometheus.Metric)
 	}{
 		{
 			name: "正常系",
-			programs: &mirakurun.ProgramsResponse{
-				{
-					ServiceID: 1,
-				},
-				{
-					ServiceID: 1,
-				},
-				{
-					ServiceID: 2,
-				},
+			programCounts: &mirakurun.ProgramCounts{
+				1: 2,
+				2: 1,
 			},
 			wantErr: false,
 			checks: func(t *testing.T, metrics []prometheus.Metric) {
@@ -79,28 +72,28 @@ func TestProgramsCollector_Collect(t *testing.T) {
 			},
 		},
 		{
-			name:     "エラー系",
-			programs: nil,
-			wantErr:  true,
-			checks:   func(t *testing.T, metrics []prometheus.Metric) {},
+			name:          "エラー系",
+			programCounts: nil,
+			wantErr:       true,
+			checks:        func(t *testing.T, metrics []prometheus.Metric) {},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mock := &mockProgramsGetter{
-				programs: tt.programs,
-				err:      nil,
+			mock := &mockProgramCountsGetter{
+				programCounts: tt.programCounts,
+				err:           nil,
 			}
 			if tt.wantErr {
 				mock.err = assert.AnError
 			}
 
-			collector := newProgramsCollector(context.Background(), nil, slog.Default())
-			collector.(*programsCollector).programsGetter = mock
+			collector := newProgramsCollector(nil, &collectorOptions{}, slog.Default())
+			collector.(*programsCollector).programCountsGetter = mock
 
 			ch := make(chan prometheus.Metric, 100)
-			err := collector.Collect(ch)
+			err := collector.Collect(context.Background(), ch)
 			if tt.wantErr {
 				require.Error(t, err)
 				return
@@ -122,7 +115,7 @@ func TestProgramsCollector_Collect(t *testing.T) {
 }
 
 func TestProgramsCollector_Describe(t *testing.T) {
-	collector := newProgramsCollector(context.Background(), nil, slog.Default())
+	collector := newProgramsCollector(nil, &collectorOptions{}, slog.Default())
 	ch := make(chan *prometheus.Desc, 64)
 	collector.Describe(ch)
 
@@ -15,7 +15,6 @@ type servicesGetter interface {
 }
 
 type servicesCollector struct {
-	ctx    context.Context
 	logger *slog.Logger
 
 	servicesGetter servicesGetter
@@ -28,7 +27,7 @@ func init() {
 	registerCollector("service", defaultEnabled, newServicesCollector)
 }
 
-func newServicesCollector(ctx context.Context, client *mirakurun.Client, logger *slog.Logger) Collector {
+func newServicesCollector(source *source, options *collectorOptions, logger *slog.Logger) Collector {
 	const subsystem = "service"
 
 	metricDefs := map[string]metricDefinition{
@@ -59,8 +58,7 @@ func newServicesCollector(ctx context.Context, client *mirakurun.Client, logger
 	}
 
 	return &servicesCollector{
-		ctx:            ctx,
-		servicesGetter: client,
+		servicesGetter: source,
 		logger:         logger,
 		metrics:        metrics,
 		metricTypes:    metricTypes,
@@ -73,8 +71,8 @@ func (c *servicesCollector) Describe(ch chan<- *prometheus.Desc) {
 	}
 }
 
-func (c *servicesCollector) Collect(ch chan<- prometheus.Metric) error {
-	services, err := c.servicesGetter.GetServices(c.ctx, c.logger)
+func (c *servicesCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric) error {
+	services, err := c.servicesGetter.GetServices(ctx, c.logger)
 	if err != nil {
 		return err
 	}
@@ -133,11 +133,11 @@ func TestServicesCollector_Collect(t *testing.T) {
 				mock.err = assert.AnError
 			}
 
-			collector := newServicesCollector(context.Background(), nil, slog.Default())
+			collector := newServicesCollector(nil, &collectorOptions{}, slog.Default())
 			collector.(*servicesCollector).servicesGetter = mock
 
 			ch := make(chan prometheus.Metric, 100)
-			err := collector.Collect(ch)
+			err := collector.Collect(context.Background(), ch)
 			if tt.wantErr {
 				require.Error(t, err)
 				return
@@ -159,7 +159,7 @@ func TestServicesCollector_Collect(t *testing.T) {
 }
 
 func TestServicesCollector_Describe(t *testing.T) {
-	collector := newServicesCollector(context.Background(), nil, slog.Default())
+	collector := newServicesCollector(nil, &collectorOptions{}, slog.Default())
 	ch := make(chan *prometheus.Desc, 64)
 	collector.Describe(ch)
 
@@ -0,0 +1,93 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Settings override the collector flags for the collectors created with them, e.g. from a
+// configuration file. Collectors created with different Settings do not affect each other.
+type Settings struct {
+	// Enabled overrides whether a collector is enabled, by collector name.
+	Enabled map[string]bool
+	// LogPatterns replace the --collector.log.pattern patterns when not nil.
+	LogPatterns map[string]string
+	// EventResources replace the --collector.events.resource resources when not nil.
+	EventResources []string
+}
+
+// collectorOptions are the options of the collectors, resolved from the flags and Settings.
+type collectorOptions struct {
+	enabled     map[string]bool
+	logPatterns []logPattern
+	// eventResources are the resources whose events are counted. Empty counts every resource.
+	eventResources []string
+}
+
+// IsKnown reports whether a collector is registered under name.
+func IsKnown(name string) bool {
+	_, ok := collectorState[name]
+	return ok
+}
+
+// Validate checks that the collectors and event resources exist and the log patterns compile.
+func (s Settings) Validate() error {
+	_, err := s.options()
+	return err
+}
+
+// options resolves s on top of the flags.
+func (s Settings) options() (*collectorOptions, error) {
+	options := &collectorOptions{
+		enabled:        make(map[string]bool, len(collectorState)),
+		logPatterns:    *logPatterns,
+		eventResources: *eventResources,
+	}
+	for name, enabled := range collectorState {
+		options.enabled[name] = *enabled
+	}
+	for name, enabled := range s.Enabled {
+		if !IsKnown(name) {
+			return nil, fmt.Errorf("unknown collector %q", name)
+		}
+		options.enabled[name] = enabled
+	}
+
+	if s.LogPatterns != nil {
+		patterns, err := s.logPatterns()
+		if err != nil {
+			return nil, err
+		}
+		options.logPatterns = patterns
+	}
+	if s.EventResources != nil {
+		for _, resource := range s.EventResources {
+			if !slices.Contains(mirakurunEventResources, resource) {
+				return nil, fmt.Errorf("unknown event resource %q, expected one of %s", resource, strings.Join(mirakurunEventResources, ", "))
+			}
+		}
+		options.eventResources = s.EventResources
+	}
+	return options, nil
+}
+
+func (s Settings) logPatterns() ([]logPattern, error) {
+	names := make([]string, 0, len(s.LogPatterns))
+	for name := range s.LogPatterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	patterns := make([]logPattern, 0, len(names))
+	for _, name := range names {
+		re, err := regexp.Compile(s.LogPatterns[name])
+		if err != nil {
+			return nil, fmt.Errorf("invalid log pattern %q: %w", name, err)
+		}
+		patterns = append(patterns, logPattern{name: name, regexp: re})
+	}
+	return patterns, nil
+}
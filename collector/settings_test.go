@@ -0,0 +1,98 @@
+package collector
+
+import (
+	"log/slog"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nasshu2916/mirakurun_exporter/mirakurun"
+)
+
+func TestSettings_Options(t *testing.T) {
+	enableCollectors(t, "status", "tuners")
+	originalPatterns := *logPatterns
+	require.NoError(t, logPatterns.Set("drop=TS Drop"))
+	t.Cleanup(func() { *logPatterns = originalPatterns })
+
+	options, err := Settings{
+		Enabled:        map[string]bool{"tuners": false, "log": true},
+		LogPatterns:    map[string]string{"error": "(?i)error", "busy": "busy"},
+		EventResources: []string{"tuner"},
+	}.options()
+	require.NoError(t, err)
+	assert.True(t, options.enabled["status"])
+	assert.False(t, options.enabled["tuners"])
+	assert.True(t, options.enabled["log"])
+	assert.Equal(t, "busy=busy,error=(?i)error", (*logPatternsValue)(&options.logPatterns).String())
+	assert.Equal(t, []string{"tuner"}, options.eventResources)
+
+	// Settings left out keep the flags.
+	options, err = Settings{}.options()
+	require.NoError(t, err)
+	assert.True(t, options.enabled["status"])
+	assert.True(t, options.enabled["tuners"])
+	assert.False(t, options.enabled["log"])
+	assert.Equal(t, "drop=TS Drop", (*logPatternsValue)(&options.logPatterns).String())
+	assert.Empty(t, options.eventResources)
+}
+
+func TestSettings_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings Settings
+		wantErr  string
+	}{
+		{
+			name:     "正常系: 空の設定",
+			settings: Settings{},
+		},
+		{
+			name:     "エラー系: 存在しない collector",
+			settings: Settings{Enabled: map[string]bool{"unknown": true}},
+			wantErr:  `unknown collector "unknown"`,
+		},
+		{
+			name:     "エラー系: 不正な正規表現",
+			settings: Settings{LogPatterns: map[string]string{"broken": "("}},
+			wantErr:  `invalid log pattern "broken"`,
+		},
+		{
+			name:     "エラー系: 存在しない event resource",
+			settings: Settings{EventResources: []string{"recorder"}},
+			wantErr:  `unknown event resource "recorder"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.settings.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestNewMirakurunCollector_Settings(t *testing.T) {
+	enableCollectors(t, "status", "tuners")
+	client, err := mirakurun.NewClient("http://localhost:40772", 1)
+	require.NoError(t, err)
+
+	current, err := NewMirakurunCollector(client, Settings{Enabled: map[string]bool{"tuners": false, "log": true}}, slog.Default())
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"status", "log"}, slices.Collect(maps.Keys(current.Collectors)))
+
+	// Collectors of other settings, and settings that fail, leave the existing collectors as they are.
+	next, err := NewMirakurunCollector(client, Settings{}, slog.Default())
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"status", "tuners"}, slices.Collect(maps.Keys(next.Collectors)))
+	_, err = NewMirakurunCollector(client, Settings{Enabled: map[string]bool{"unknown": true}}, slog.Default())
+	assert.Error(t, err)
+	assert.ElementsMatch(t, []string{"status", "log"}, slices.Collect(maps.Keys(current.Collectors)))
+}
@@ -0,0 +1,151 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/nasshu2916/mirakurun_exporter/mirakurun"
+)
+
+// source sits between the collectors and mirakurun.Client.
+// Concurrent scrapes share one in-flight request per endpoint, and collectors running in the
+// same scrape see the same response of an endpoint (see withSnapshot).
+//
+// A shared request runs with the values but not the cancellation of the scrape that started it,
+// so that a scrape giving up does not fail the scrapes waiting for the same request. It is bounded
+// by the timeout and retries of the client, and by sharedFetchTimeout.
+type source struct {
+	client   *mirakurun.Client
+	group    singleflight.Group
+	versions versionCache
+}
+
+// sharedFetchTimeout bounds a shared request, e.g. when the client has no request timeout.
+const sharedFetchTimeout = time.Minute
+
+func newSource(client *mirakurun.Client) *source {
+	return &source{client: client, versions: versionCache{now: time.Now, refreshTimeout: sharedFetchTimeout}}
+}
+
+// snapshot memoizes the responses fetched during a single scrape.
+type snapshot struct {
+	mu      sync.Mutex
+	entries map[string]*snapshotEntry
+}
+
+type snapshotEntry struct {
+	once  sync.Once
+	value any
+	err   error
+}
+
+type snapshotContextKey struct{}
+
+// withSnapshot returns a context in which every endpoint is fetched at most once.
+func withSnapshot(ctx context.Context) context.Context {
+	return context.WithValue(ctx, snapshotContextKey{}, &snapshot{entries: make(map[string]*snapshotEntry)})
+}
+
+func snapshotFromContext(ctx context.Context) *snapshot {
+	snap, _ := ctx.Value(snapshotContextKey{}).(*snapshot)
+	return snap
+}
+
+func (snap *snapshot) load(key string, fetch func() (any, error)) (any, error) {
+	snap.mu.Lock()
+	entry, ok := snap.entries[key]
+	if !ok {
+		entry = &snapshotEntry{}
+		snap.entries[key] = entry
+	}
+	snap.mu.Unlock()
+
+	entry.once.Do(func() {
+		entry.value, entry.err = fetch()
+	})
+	return entry.value, entry.err
+}
+
+func fetch[T any](ctx context.Context, s *source, key string, get func(ctx context.Context) (*T, error)) (*T, error) {
+	shared := func() (any, error) {
+		ch := s.group.DoChan(key, func() (any, error) {
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sharedFetchTimeout)
+			defer cancel()
+			return get(ctx)
+		})
+		select {
+		case res := <-ch:
+			return res.Val, res.Err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	var value any
+	var err error
+	if snap := snapshotFromContext(ctx); snap != nil {
+		value, err = snap.load(key, shared)
+	} else {
+		value, err = shared()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return value.(*T), nil
+}
+
+func (s *source) GetStatus(ctx context.Context, logger *slog.Logger) (*mirakurun.StatusResponse, error) {
+	return fetch(ctx, s, "status", func(ctx context.Context) (*mirakurun.StatusResponse, error) {
+		return s.client.GetStatus(ctx, logger)
+	})
+}
+
+func (s *source) GetTuners(ctx context.Context, logger *slog.Logger) (*mirakurun.TunersResponse, error) {
+	return fetch(ctx, s, "tuners", func(ctx context.Context) (*mirakurun.TunersResponse, error) {
+		return s.client.GetTuners(ctx, logger)
+	})
+}
+
+func (s *source) GetChannels(ctx context.Context, logger *slog.Logger) (*mirakurun.ChannelsResponse, error) {
+	return fetch(ctx, s, "channels", func(ctx context.Context) (*mirakurun.ChannelsResponse, error) {
+		return s.client.GetChannels(ctx, logger)
+	})
+}
+
+func (s *source) GetServices(ctx context.Context, logger *slog.Logger) (*mirakurun.ServicesResponse, error) {
+	return fetch(ctx, s, "services", func(ctx context.Context) (*mirakurun.ServicesResponse, error) {
+		return s.client.GetServices(ctx, logger)
+	})
+}
+
+func (s *source) GetProgramCounts(ctx context.Context, logger *slog.Logger) (*mirakurun.ProgramCounts, error) {
+	return fetch(ctx, s, "program_counts", func(ctx context.Context) (*mirakurun.ProgramCounts, error) {
+		return s.client.GetProgramCounts(ctx, logger)
+	})
+}
+
+func (s *source) GetJobs(ctx context.Context, logger *slog.Logger) (*mirakurun.JobsResponse, error) {
+	return fetch(ctx, s, "jobs", func(ctx context.Context) (*mirakurun.JobsResponse, error) {
+		return s.client.GetJobs(ctx, logger)
+	})
+}
+
+func (s *source) GetVersion(ctx context.Context, logger *slog.Logger) (*mirakurun.VersionResponse, error) {
+	return fetch(ctx, s, "version", func(ctx context.Context) (*mirakurun.VersionResponse, error) {
+		return s.client.GetVersion(ctx, logger)
+	})
+}
+
+// SubscribeEvents follows the event stream of Mirakurun. Events are not part of a snapshot.
+func (s *source) SubscribeEvents(ctx context.Context, fn func(event *mirakurun.Event), logger *slog.Logger) error {
+	return s.client.SubscribeEvents(ctx, fn, logger)
+}
+
+// SubscribeLog follows the log stream of Mirakurun. Log lines are not part of a snapshot.
+func (s *source) SubscribeLog(ctx context.Context, fn func(line mirakurun.LogLine), logger *slog.Logger) error {
+	return s.client.SubscribeLog(ctx, fn, logger)
+}
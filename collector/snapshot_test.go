@@ -0,0 +1,113 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nasshu2916/mirakurun_exporter/mirakurun"
+	"github.com/nasshu2916/mirakurun_exporter/mirakurun/fixture"
+	"github.com/nasshu2916/mirakurun_exporter/mirakuruntest"
+)
+
+func newCountingSource(t *testing.T, delay time.Duration) (*source, func() int) {
+	t.Helper()
+	srv := mirakuruntest.NewServer(t, os.DirFS("../test/mirakurun"))
+	srv.SetFault("/api/tuners", fixture.Fault{Latency: delay})
+
+	client, err := mirakurun.NewClient(srv.URL, 1)
+	require.NoError(t, err)
+
+	return newSource(client), func() int { return srv.Requests("/api/tuners") }
+}
+
+func TestSource_CoalescesConcurrentFetches(t *testing.T) {
+	source, calls := newCountingSource(t, 100*time.Millisecond)
+
+	const scrapes = 5
+	results := make([]*mirakurun.TunersResponse, scrapes)
+	wg := sync.WaitGroup{}
+	wg.Add(scrapes)
+	for i := 0; i < scrapes; i++ {
+		go func(i int) {
+			defer wg.Done()
+			ctx := withSnapshot(context.Background())
+			tuners, err := source.GetTuners(ctx, slog.Default())
+			assert.NoError(t, err)
+			results[i] = tuners
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, calls())
+	for _, tuners := range results {
+		assert.Same(t, results[0], tuners)
+	}
+}
+
+func TestSource_SnapshotIsConsistentWithinScrape(t *testing.T) {
+	source, calls := newCountingSource(t, 0)
+
+	ctx := withSnapshot(context.Background())
+	first, err := source.GetTuners(ctx, slog.Default())
+	require.NoError(t, err)
+	second, err := source.GetTuners(ctx, slog.Default())
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, calls())
+	assert.Same(t, first, second)
+
+	// The next scrape takes a new snapshot.
+	third, err := source.GetTuners(withSnapshot(context.Background()), slog.Default())
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, calls())
+	assert.NotSame(t, first, third)
+}
+
+func TestSource_WaiterStopsOnContextDone(t *testing.T) {
+	source, _ := newCountingSource(t, 300*time.Millisecond)
+
+	go func() {
+		_, _ = source.GetTuners(context.Background(), slog.Default())
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	begin := time.Now()
+	_, err := source.GetTuners(ctx, slog.Default())
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(begin), 200*time.Millisecond)
+}
+
+func TestSource_CancelledScrapeDoesNotFailJoinedScrapes(t *testing.T) {
+	source, calls := newCountingSource(t, 200*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := source.GetTuners(withSnapshot(ctx), slog.Default())
+		first <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	second := make(chan error, 1)
+	go func() {
+		_, err := source.GetTuners(withSnapshot(context.Background()), slog.Default())
+		second <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	assert.ErrorIs(t, <-first, context.Canceled)
+	assert.NoError(t, <-second)
+	assert.Equal(t, 1, calls())
+}
@@ -14,7 +14,6 @@ type statusGetter interface {
 }
 
 type statusCollector struct {
-	ctx    context.Context
 	logger *slog.Logger
 
 	statusGetter statusGetter
@@ -27,7 +26,7 @@ func init() {
 	registerCollector("status", defaultEnabled, newStatusCollector)
 }
 
-func newStatusCollector(ctx context.Context, client *mirakurun.Client, logger *slog.Logger) Collector {
+func newStatusCollector(source *source, options *collectorOptions, logger *slog.Logger) Collector {
 	const subsystem = "status"
 
 	metricDefs := map[string]metricDefinition{
@@ -99,8 +98,7 @@ func newStatusCollector(ctx context.Context, client *mirakurun.Client, logger *s
 	}
 
 	return &statusCollector{
-		ctx:          ctx,
-		statusGetter: client,
+		statusGetter: source,
 		logger:       logger,
 		metrics:      metrics,
 		metricTypes:  metricTypes,
@@ -113,8 +111,8 @@ func (c *statusCollector) Describe(ch chan<- *prometheus.Desc) {
 	}
 }
 
-func (c *statusCollector) Collect(ch chan<- prometheus.Metric) error {
-	status, err := c.statusGetter.GetStatus(c.ctx, c.logger)
+func (c *statusCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric) error {
+	status, err := c.statusGetter.GetStatus(ctx, c.logger)
 	if err != nil {
 		return err
 	}
@@ -191,7 +189,10 @@ func (c *statusCollector) Collect(ch chan<- prometheus.Metric) error {
 		)
 	}
 
-	// Timer accuracy metrics
+	// Timer accuracy metrics, reported by Mirakurun 3.9 and later
+	if version, err := mirakurun.ParseVersion(status.Version); err == nil && !version.Supports(mirakurun.FeatureTimerAccuracy) {
+		return nil
+	}
 	timerFields := []string{"avg", "min", "max"}
 	timerPeriods := map[string]struct {
 		metric string
@@ -32,7 +32,7 @@ func TestStatusCollector_Collect(t *testing.T) {
 		{
 			name: "正常系",
 			status: &mirakurun.StatusResponse{
-				Version: "1.0.0",
+				Version: "4.0.0",
 				Process: mirakurun.Process{
 					Versions: map[string]string{
 						"node": "v16.0.0",
@@ -121,7 +121,7 @@ func TestStatusCollector_Collect(t *testing.T) {
 				require.Len(t, versionMetrics, 1)
 				assert.Equal(t, prometheus.GaugeValue, versionMetrics[0].Type)
 				assert.Equal(t, 1.0, versionMetrics[0].Value)
-				assert.Equal(t, "1.0.0", versionMetrics[0].Labels["mirakurun"])
+				assert.Equal(t, "4.0.0", versionMetrics[0].Labels["mirakurun"])
 				assert.Equal(t, "v16.0.0", versionMetrics[0].Labels["node"])
 
 				// プロセスメトリクスの検証
@@ -210,6 +210,19 @@ func TestStatusCollector_Collect(t *testing.T) {
 				assert.Equal(t, 3.5, timerValues["M15"]["max"])
 			},
 		},
+		{
+			name: "正常系: timerAccuracy のない Mirakurun 3.8",
+			status: &mirakurun.StatusResponse{
+				Version: "3.8.1",
+			},
+			wantErr: false,
+			checks: func(t *testing.T, metrics []prometheus.Metric) {
+				assert.Equal(t, 16, len(metrics))
+				for _, metric := range metrics {
+					assert.NotContains(t, metric.Desc().String(), "timer_accuracy")
+				}
+			},
+		},
 		{
 			name:    "エラー系",
 			status:  nil,
@@ -228,11 +241,11 @@ func TestStatusCollector_Collect(t *testing.T) {
 				mock.err = assert.AnError
 			}
 
-			collector := newStatusCollector(context.Background(), nil, slog.Default())
+			collector := newStatusCollector(nil, &collectorOptions{}, slog.Default())
 			collector.(*statusCollector).statusGetter = mock
 
 			ch := make(chan prometheus.Metric, 100)
-			err := collector.Collect(ch)
+			err := collector.Collect(context.Background(), ch)
 			if tt.wantErr {
 				require.Error(t, err)
 				return
@@ -254,7 +267,7 @@ func TestStatusCollector_Collect(t *testing.T) {
 }
 
 func TestStatusCollector_Describe(t *testing.T) {
-	collector := newStatusCollector(context.Background(), nil, slog.Default())
+	collector := newStatusCollector(nil, &collectorOptions{}, slog.Default())
 	ch := make(chan *prometheus.Desc, 64)
 	collector.Describe(ch)
 
@@ -16,7 +16,6 @@ type tunersGetter interface {
 }
 
 type tunerCollector struct {
-	ctx    context.Context
 	logger *slog.Logger
 
 	tunersGetter tunersGetter
@@ -29,7 +28,7 @@ func init() {
 	registerCollector("tuners", defaultEnabled, newTunerCollector)
 }
 
-func newTunerCollector(ctx context.Context, client *mirakurun.Client, logger *slog.Logger) Collector {
+func newTunerCollector(source *source, options *collectorOptions, logger *slog.Logger) Collector {
 	const subsystem = "tuners"
 
 	metricDefs := map[string]metricDefinition{
@@ -102,8 +101,7 @@ func newTunerCollector(ctx context.Context, client *mirakurun.Client, logger *sl
 	}
 
 	return &tunerCollector{
-		ctx:          ctx,
-		tunersGetter: client,
+		tunersGetter: source,
 		logger:       logger,
 		metrics:      metrics,
 		metricTypes:  metricTypes,
@@ -116,8 +114,8 @@ func (c *tunerCollector) Describe(ch chan<- *prometheus.Desc) {
 	}
 }
 
-func (c *tunerCollector) Collect(ch chan<- prometheus.Metric) error {
-	tuners, err := c.tunersGetter.GetTuners(c.ctx, c.logger)
+func (c *tunerCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric) error {
+	tuners, err := c.tunersGetter.GetTuners(ctx, c.logger)
 	if err != nil {
 		return err
 	}
@@ -227,11 +227,11 @@ func TestTunerCollector_Collect(t *testing.T) {
 				mock.err = assert.AnError
 			}
 
-			collector := newTunerCollector(context.Background(), nil, slog.Default())
+			collector := newTunerCollector(nil, &collectorOptions{}, slog.Default())
 			collector.(*tunerCollector).tunersGetter = mock
 
 			ch := make(chan prometheus.Metric, 100)
-			err := collector.Collect(ch)
+			err := collector.Collect(context.Background(), ch)
 			if tt.wantErr {
 				require.Error(t, err)
 				return
@@ -253,7 +253,7 @@ func TestTunerCollector_Collect(t *testing.T) {
 }
 
 func TestTunerCollector_Describe(t *testing.T) {
-	collector := newTunerCollector(context.Background(), nil, slog.Default())
+	collector := newTunerCollector(nil, &collectorOptions{}, slog.Default())
 	ch := make(chan *prometheus.Desc, 64)
 	collector.Describe(ch)
 
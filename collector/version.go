@@ -14,7 +14,6 @@ type versionGetter interface {
 }
 
 type versionCollector struct {
-	ctx    context.Context
 	logger *slog.Logger
 
 	versionGetter versionGetter
@@ -27,7 +26,7 @@ func init() {
 	registerCollector("version", defaultDisabled, newVersionCollector)
 }
 
-func newVersionCollector(ctx context.Context, client *mirakurun.Client, logger *slog.Logger) Collector {
+func newVersionCollector(source *source, options *collectorOptions, logger *slog.Logger) Collector {
 	const subsystem = "version"
 
 	metricDefs := map[string]metricDefinition{
@@ -52,8 +51,7 @@ func newVersionCollector(ctx context.Context, client *mirakurun.Client, logger *
 	}
 
 	return &versionCollector{
-		ctx:           ctx,
-		versionGetter: client,
+		versionGetter: source,
 		logger:        logger,
 		metrics:       metrics,
 		metricTypes:   metricTypes,
@@ -66,8 +64,8 @@ func (c *versionCollector) Describe(ch chan<- *prometheus.Desc) {
 	}
 }
 
-func (c *versionCollector) Collect(ch chan<- prometheus.Metric) error {
-	version, err := c.versionGetter.GetVersion(c.ctx, c.logger)
+func (c *versionCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric) error {
+	version, err := c.versionGetter.GetVersion(ctx, c.logger)
 	if err != nil {
 		return err
 	}
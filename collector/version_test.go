@@ -86,11 +86,11 @@ func TestVersionCollector_Collect(t *testing.T) {
 				mock.err = assert.AnError
 			}
 
-			collector := newVersionCollector(context.Background(), nil, slog.Default())
+			collector := newVersionCollector(nil, &collectorOptions{}, slog.Default())
 			collector.(*versionCollector).versionGetter = mock
 
 			ch := make(chan prometheus.Metric, 100)
-			err := collector.Collect(ch)
+			err := collector.Collect(context.Background(), ch)
 			if tt.wantErr {
 				require.Error(t, err)
 				return
@@ -112,7 +112,7 @@ func TestVersionCollector_Collect(t *testing.T) {
 }
 
 func TestVersionCollector_Describe(t *testing.T) {
-	collector := newVersionCollector(context.Background(), nil, slog.Default())
+	collector := newVersionCollector(nil, &collectorOptions{}, slog.Default())
 	ch := make(chan *prometheus.Desc, 64)
 	collector.Describe(ch)
 
@@ -0,0 +1,167 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/nasshu2916/mirakurun_exporter/collector"
+	"github.com/nasshu2916/mirakurun_exporter/mirakurun"
+)
+
+// Config is the file given with --config.file. Settings it leaves out keep the values of the flags.
+type Config struct {
+	// Mirakurun lists the instances to scrape, replacing --mirakurun.url.
+	Mirakurun []MirakurunConfig `yaml:"mirakurun"`
+	// Collectors configures the collectors by name.
+	Collectors map[string]CollectorConfig `yaml:"collectors"`
+}
+
+// MirakurunConfig is a Mirakurun instance and how to connect to it. Its settings take precedence
+// over the --mirakurun.* flags.
+type MirakurunConfig struct {
+	URL             string            `yaml:"url"`
+	Timeout         time.Duration     `yaml:"timeout"`
+	BasicAuth       *BasicAuth        `yaml:"basic_auth"`
+	BearerTokenFile string            `yaml:"bearer_token_file"`
+	Headers         map[string]string `yaml:"headers"`
+	TLSConfig       *TLSConfig        `yaml:"tls_config"`
+}
+
+type BasicAuth struct {
+	Username     string `yaml:"username"`
+	PasswordFile string `yaml:"password_file"`
+}
+
+type TLSConfig struct {
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// CollectorConfig overrides the flags of a collector.
+type CollectorConfig struct {
+	// Enabled overrides --collector.<name> when set.
+	Enabled *bool `yaml:"enabled"`
+	// Patterns replace --collector.log.pattern, as pattern names to regular expressions.
+	// Only the log collector accepts them.
+	Patterns map[string]string `yaml:"patterns"`
+	// Resources replace --collector.events.resource. Only the events collector accepts them.
+	Resources []string `yaml:"resources"`
+}
+
+// Load reads and validates the file at path.
+func Load(path string) (*Config, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(buf)
+}
+
+// Parse decodes and validates a configuration. Unknown fields are errors.
+func Parse(buf []byte) (*Config, error) {
+	cfg := &Config{}
+	decoder := yaml.NewDecoder(bytes.NewReader(buf))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks the configuration without touching the files it refers to.
+func (cfg *Config) Validate() error {
+	urls := make(map[string]bool, len(cfg.Mirakurun))
+	for i, m := range cfg.Mirakurun {
+		if m.URL == "" {
+			return fmt.Errorf("mirakurun[%d]: url is missing", i)
+		}
+		// Instances are told apart by their label, which leaves out the credentials.
+		instance := mirakurun.InstanceURL(m.URL)
+		if urls[instance] {
+			return fmt.Errorf("mirakurun[%d]: duplicate url %q", i, instance)
+		}
+		urls[instance] = true
+		if m.Timeout < 0 {
+			return fmt.Errorf("mirakurun[%d]: timeout must not be negative", i)
+		}
+		if m.BasicAuth != nil && m.BasicAuth.Username == "" {
+			return fmt.Errorf("mirakurun[%d]: basic_auth.username is missing", i)
+		}
+		if m.BasicAuth != nil && m.BearerTokenFile != "" {
+			return fmt.Errorf("mirakurun[%d]: basic_auth and bearer_token_file are mutually exclusive", i)
+		}
+	}
+
+	for name, c := range cfg.Collectors {
+		if !collector.IsKnown(name) {
+			return fmt.Errorf("collectors: unknown collector %q", name)
+		}
+		if c.Patterns != nil && name != "log" {
+			return fmt.Errorf("collectors.%s: patterns are only supported by the log collector", name)
+		}
+		if c.Resources != nil && name != "events" {
+			return fmt.Errorf("collectors.%s: resources are only supported by the events collector", name)
+		}
+	}
+	if err := cfg.Settings().Validate(); err != nil {
+		return fmt.Errorf("collectors: %w", err)
+	}
+	return nil
+}
+
+// Settings returns the collector settings of the configuration.
+func (cfg *Config) Settings() collector.Settings {
+	settings := collector.Settings{Enabled: make(map[string]bool)}
+	for name, c := range cfg.Collectors {
+		if c.Enabled != nil {
+			settings.Enabled[name] = *c.Enabled
+		}
+		switch name {
+		case "log":
+			settings.LogPatterns = c.Patterns
+		case "events":
+			settings.EventResources = c.Resources
+		}
+	}
+	return settings
+}
+
+// HasAuth reports whether the instance sets its own authentication, which replaces that of the flags.
+func (m MirakurunConfig) HasAuth() bool {
+	return m.BasicAuth != nil || m.BearerTokenFile != ""
+}
+
+// ClientOptions returns the options of the instance. They are meant to be appended to the options
+// from the flags, so that they take precedence. The authentication of the flags must be left out
+// when HasAuth reports true.
+func (m MirakurunConfig) ClientOptions() []mirakurun.Option {
+	var opts []mirakurun.Option
+	if m.Timeout > 0 {
+		opts = append(opts, mirakurun.WithTimeout(m.Timeout))
+	}
+	for name, value := range m.Headers {
+		opts = append(opts, mirakurun.WithHeader(name, value))
+	}
+	if m.BasicAuth != nil {
+		opts = append(opts, mirakurun.WithBasicAuthPasswordFile(m.BasicAuth.Username, m.BasicAuth.PasswordFile))
+	}
+	if m.BearerTokenFile != "" {
+		opts = append(opts, mirakurun.WithBearerTokenFile(m.BearerTokenFile))
+	}
+	if m.TLSConfig != nil {
+		opts = append(opts, mirakurun.WithTLSConfig(mirakurun.TLSConfig(*m.TLSConfig)))
+	}
+	return opts
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	lastReloadSuccessfulDesc = prometheus.NewDesc(
+		"mirakurun_exporter_config_last_reload_successful",
+		"mirakurun_exporter: Whether the last configuration reload succeeded",
+		nil,
+		nil,
+	)
+	lastReloadSuccessTimestampDesc = prometheus.NewDesc(
+		"mirakurun_exporter_config_last_reload_success_timestamp_seconds",
+		"mirakurun_exporter: Unix time of the last successful configuration reload",
+		nil,
+		nil,
+	)
+)
+
+// Reloader loads the file at a path and hands it to an apply function, on start and whenever
+// asked to reload. A file that fails to load or apply leaves the previous configuration in place.
+type Reloader struct {
+	path   string
+	apply  func(cfg *Config) error
+	logger *slog.Logger
+
+	mu          sync.Mutex
+	success     bool
+	lastSuccess time.Time
+}
+
+func NewReloader(path string, apply func(cfg *Config) error, logger *slog.Logger) *Reloader {
+	return &Reloader{
+		path:   path,
+		apply:  apply,
+		logger: logger,
+	}
+}
+
+// Reload loads and applies the file. Concurrent reloads run one after another.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := r.reload()
+	r.success = err == nil
+	if err != nil {
+		r.logger.Error("failed to reload config", "file", r.path, "err", err)
+		return err
+	}
+	r.lastSuccess = time.Now()
+	r.logger.Info("loaded config", "file", r.path)
+	return nil
+}
+
+func (r *Reloader) reload() error {
+	cfg, err := Load(r.path)
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %w", r.path, err)
+	}
+	if err := r.apply(cfg); err != nil {
+		return fmt.Errorf("failed to apply %s: %w", r.path, err)
+	}
+	return nil
+}
+
+// WatchSignals reloads on SIGHUP until ctx is done.
+func (r *Reloader) WatchSignals(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			_ = r.Reload()
+		}
+	}
+}
+
+// Handler reloads on POST requests and reports the error of a failed reload.
+func (r *Reloader) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "only POST requests are allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := r.Reload(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (r *Reloader) Describe(ch chan<- *prometheus.Desc) {
+	ch <- lastReloadSuccessfulDesc
+	ch <- lastReloadSuccessTimestampDesc
+}
+
+func (r *Reloader) Collect(ch chan<- prometheus.Metric) {
+	r.mu.Lock()
+	success, lastSuccess := r.success, r.lastSuccess
+	r.mu.Unlock()
+
+	var successValue, lastSuccessValue float64
+	if success {
+		successValue = 1
+	}
+	if !lastSuccess.IsZero() {
+		lastSuccessValue = float64(lastSuccess.UnixNano()) / 1e9
+	}
+	ch <- prometheus.MustNewConstMetric(lastReloadSuccessfulDesc, prometheus.GaugeValue, successValue)
+	ch <- prometheus.MustNewConstMetric(lastReloadSuccessTimestampDesc, prometheus.GaugeValue, lastSuccessValue)
+}
@@ -1,37 +1,99 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/alecthomas/kingpin/v2"
 	"github.com/nasshu2916/mirakurun_exporter/collector"
+	"github.com/nasshu2916/mirakurun_exporter/config"
 	"github.com/nasshu2916/mirakurun_exporter/mirakurun"
+	"github.com/nasshu2916/mirakurun_exporter/mirakurun/fixture"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
+	versioncollector "github.com/prometheus/client_golang/prometheus/collectors/version"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/common/promslog"
 	"github.com/prometheus/common/promslog/flag"
 	"github.com/prometheus/common/version"
+	"github.com/prometheus/exporter-toolkit/web"
+	"github.com/prometheus/exporter-toolkit/web/kingpinflag"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
 var (
-	addr                     = kingpin.Flag("addr", "Listen address for web server").Default(":8080").String()
-	mirakurunUrl             = kingpin.Flag("mirakurun.url", "Mirakurun URL").Default("http://localhost:40772").String()
+	configFile               = kingpin.Flag("config.file", "YAML file configuring the Mirakurun instances and the collectors. Reloaded on SIGHUP or a POST to /-/reload.").String()
+	addr                     = kingpin.Flag("addr", "Deprecated: use --web.listen-address. Listen address for web server, replacing --web.listen-address when set.").String()
+	mirakurunUrls            = kingpin.Flag("mirakurun.url", "Mirakurun URL (http://host:port or unix:///path/to/socket). Can be repeated to scrape several instances.").Default("http://localhost:40772").Strings()
 	mirakurunRequestTimeout  = kingpin.Flag("mirakurun.request.timeout", "Mirakurun request timeout in seconds").Default("5").Int()
+	mirakurunRetryMax        = kingpin.Flag("mirakurun.retry.max", "Maximum number of retries for a failed Mirakurun request").Default("2").Int()
+	mirakurunRetryMinBackoff = kingpin.Flag("mirakurun.retry.min-backoff", "Initial wait before retrying a failed Mirakurun request").Default("100ms").Duration()
+	mirakurunRetryMaxBackoff = kingpin.Flag("mirakurun.retry.max-backoff", "Maximum wait before retrying a failed Mirakurun request").Default("1s").Duration()
+	mirakurunCacheTTLs       = kingpin.Flag("mirakurun.cache.ttl", "Cache Mirakurun responses of an endpoint as ENDPOINT=DURATION, e.g. programs=5m. Can be repeated.").StringMap()
+	mirakurunCacheMaxStale   = kingpin.Flag("mirakurun.cache.max-stale", "How long an expired cached response is served while it is refreshed in the background or when refreshing it fails").Default("10m").Duration()
+	mirakurunMaxResponseSize = kingpin.Flag("mirakurun.max-response-size", "Maximum size of a Mirakurun response body. 0 disables the limit.").Default("128MB").Bytes()
+	mirakurunStrictSchema    = kingpin.Flag("mirakurun.strict-schema", "Report fields of Mirakurun responses unknown to or missing from the exporter in mirakurun_exporter_schema_* metrics").Default("false").Bool()
+	mirakurunPollInterval    = kingpin.Flag("mirakurun.poll.interval", "Poll Mirakurun in the background at this interval and serve the last completed poll on /metrics. 0 queries Mirakurun on every scrape.").Default("0s").Duration()
+	mirakurunHeaders         = kingpin.Flag("mirakurun.header", "Extra header sent to Mirakurun as \"Name: value\". Can be repeated.").Strings()
+	mirakurunUserAgent       = kingpin.Flag("mirakurun.user-agent", "User-Agent sent to Mirakurun").Default("mirakurun_exporter").String()
+	mirakurunBasicAuthUser   = kingpin.Flag("mirakurun.basic-auth.username", "Username for basic authentication to Mirakurun").String()
+	mirakurunBasicAuthPass   = kingpin.Flag("mirakurun.basic-auth.password-file", "File containing the password for basic authentication to Mirakurun").String()
+	mirakurunBearerToken     = kingpin.Flag("mirakurun.bearer-token-file", "File containing the bearer token sent to Mirakurun").String()
+	mirakurunTLSCAFile       = kingpin.Flag("mirakurun.tls.ca-file", "CA certificate file to verify Mirakurun").String()
+	mirakurunTLSCertFile     = kingpin.Flag("mirakurun.tls.cert-file", "Client certificate file for Mirakurun").String()
+	mirakurunTLSKeyFile      = kingpin.Flag("mirakurun.tls.key-file", "Client certificate key file for Mirakurun").String()
+	mirakurunTLSServerName   = kingpin.Flag("mirakurun.tls.server-name", "Server name used to verify the Mirakurun certificate").String()
+	mirakurunTLSInsecure     = kingpin.Flag("mirakurun.tls.insecure-skip-verify", "Disable verification of the Mirakurun certificate").Default("false").Bool()
+	disableExporterMetrics   = kingpin.Flag("web.disable-exporter-metrics", "Exclude the metrics of the exporter process (go_*, process_*, promhttp_*) from /metrics.").Default("false").Bool()
 	disableDefaultCollectors = kingpin.Flag("collector.disable-defaults", "Set all collectors to disabled by default.").Default("false").Bool()
+	probeAllowedTargets      = kingpin.Flag("probe.allowed-target", "Mirakurun URL that /probe may scrape, whose host, port and path may be path.Match patterns, e.g. http://192.168.1.*:40772. \"*\" allows any target. Can be repeated.").Strings()
+	probeMaxTargets          = kingpin.Flag("probe.max-targets", "Number of /probe targets whose clients and collectors are kept. The least recently probed target is dropped beyond it, 0 keeps all.").Default("100").Int()
+	probeTargetIdleTimeout   = kingpin.Flag("probe.target-idle-timeout", "Drop the client and collectors of a /probe target that was not probed for this long, 0 keeps idle targets.").Default("10m").Duration()
+	probeModules             = collector.ProbeModules{}
+
+	recordCommand          = kingpin.Command("record", "Fetch every supported endpoint from Mirakurun and write a fixture directory in the test/mirakurun layout.")
+	recordDir              = recordCommand.Arg("dir", "Directory to write the fixtures to").Required().String()
+	recordRedactClientIPs  = recordCommand.Flag("redact.client-ips", "Replace client IP addresses in tuner user IDs and in the log").Default("true").Bool()
+	recordRedactProcessEnv = recordCommand.Flag("redact.process-env", "Replace the values of process.env in /api/status").Default("true").Bool()
+
+	replayCommand       = kingpin.Command("replay", "Serve a fixture directory as a fake Mirakurun.")
+	replayDir           = replayCommand.Arg("dir", "Fixture directory, e.g. one written by record").Required().ExistingDir()
+	replayListenAddress = replayCommand.Flag("listen-address", "Listen address of the fake Mirakurun").Default(":40772").String()
 )
 
 func main() {
 	promslogConfig := &promslog.Config{}
 	flag.AddFlags(kingpin.CommandLine, promslogConfig)
-	kingpin.Version(version.Print("node_exporter"))
+	toolkitFlags := kingpinflag.AddFlags(kingpin.CommandLine, ":8080")
+	kingpin.Version(version.Print("mirakurun_exporter"))
 	kingpin.CommandLine.UsageWriter(os.Stdout)
 	kingpin.HelpFlag.Short('h')
-	kingpin.Parse()
+	kingpin.Command("serve", "Run the exporter.").Default()
+	kingpin.Flag("probe.module", "Module selectable with the module parameter of /probe, running the listed collectors within TIMEOUT. Can be repeated.").
+		PlaceHolder("NAME=COLLECTOR[,COLLECTOR...][:TIMEOUT]").
+		SetValue(probeModules)
+	command := kingpin.Parse()
 
 	logger := promslog.New(promslogConfig)
 
+	switch command {
+	case recordCommand.FullCommand():
+		if err := record(logger); err != nil {
+			logger.Error("Error recording fixtures", "err", err)
+			os.Exit(1)
+		}
+		return
+	case replayCommand.FullCommand():
+		logger.Info("Replaying fixtures", "dir", *replayDir, "address", *replayListenAddress)
+		log.Fatal(http.ListenAndServe(*replayListenAddress, fixture.NewHandler(os.DirFS(*replayDir))))
+	}
+
 	if *disableDefaultCollectors {
 		collector.DisableDefaultCollectors()
 	}
@@ -39,27 +101,272 @@ func main() {
 	logger.Info("Starting mirakurun_exporter", "version", version.Info())
 	logger.Info("Build context", "build_context", version.BuildContext())
 
-	client, err := mirakurun.NewClient(*mirakurunUrl, *mirakurunRequestTimeout)
+	clientOptions, err := mirakurunClientOptions()
 	if err != nil {
 		fmt.Println("Error creating client:", err)
 		os.Exit(1)
 	}
 
+	// reg holds the metrics of the exporter process, which live as long as the process.
 	reg := prometheus.NewRegistry()
-
 	reg.MustRegister(
 		collectors.NewGoCollector(),
 		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
 	)
+	// exporterReg holds the metrics of the exporter that are served regardless of --web.disable-exporter-metrics.
+	exporterReg := prometheus.NewRegistry()
+	exporterReg.MustRegister(versioncollector.NewCollector("mirakurun_exporter"))
+	exporterGatherers := []prometheus.Gatherer{exporterReg}
+	if !*disableExporterMetrics {
+		exporterGatherers = append(exporterGatherers, reg)
+	}
+
+	var current atomic.Pointer[generation]
+	swap := func(cfg *config.Config) error {
+		var settings collector.Settings
+		if cfg != nil {
+			settings = cfg.Settings()
+		}
+		return swapGeneration(&current, func() (*generation, error) {
+			return newGeneration(mirakurunTargets(cfg), settings, clientOptions, logger, exporterGatherers...)
+		})
+	}
+
+	if *configFile == "" {
+		if err := swap(nil); err != nil {
+			fmt.Println("Error creating collector:", err)
+			os.Exit(1)
+		}
+	} else {
+		reloader := config.NewReloader(*configFile, swap, logger)
+		exporterReg.MustRegister(reloader)
+		if err := reloader.Reload(); err != nil {
+			os.Exit(1)
+		}
+		go reloader.WatchSignals(context.Background())
+		http.HandleFunc("/-/reload", reloader.Handler())
+	}
 
-	http.HandleFunc("/metrics", collector.MetricsHandler(client, logger))
+	var metricsHandler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current.Load().metrics.ServeHTTP(w, r)
+	})
+	if !*disableExporterMetrics {
+		metricsHandler = promhttp.InstrumentMetricHandler(reg, metricsHandler)
+	}
+	http.Handle("/metrics", metricsHandler)
+	http.HandleFunc("/probe", func(w http.ResponseWriter, r *http.Request) {
+		current.Load().probe.ServeHTTP(w, r)
+	})
 
 	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("{}"))
 	})
 
-	log.Println("Mirakurun URL:", *mirakurunUrl)
-	log.Println("Exporter running on ", *addr)
-	log.Fatal(http.ListenAndServe(*addr, nil))
+	if *addr != "" {
+		logger.Warn("--addr is deprecated, use --web.listen-address instead")
+		*toolkitFlags.WebListenAddresses = []string{*addr}
+	}
+	server := &http.Server{}
+	if err := web.ListenAndServe(server, toolkitFlags, logger); err != nil {
+		logger.Error("Error running web server", "err", err)
+		os.Exit(1)
+	}
+}
+
+// generation holds the collectors built from a configuration and the handlers serving them.
+// A reload builds a new generation and closes the previous one.
+type generation struct {
+	metrics http.Handler
+	probe   http.Handler
+	close   func()
+}
+
+// swapGeneration makes the generation built by next the current one and closes the previous one.
+// When next fails, the current generation keeps serving with its own collectors and settings.
+func swapGeneration(current *atomic.Pointer[generation], next func() (*generation, error)) error {
+	g, err := next()
+	if err != nil {
+		return err
+	}
+	if previous := current.Swap(g); previous != nil {
+		previous.close()
+	}
+	return nil
+}
+
+// mirakurunTargets returns the Mirakurun instances of cfg, or those of --mirakurun.url when cfg
+// is nil or lists none.
+func mirakurunTargets(cfg *config.Config) []config.MirakurunConfig {
+	if cfg != nil && len(cfg.Mirakurun) > 0 {
+		return cfg.Mirakurun
+	}
+	var targets []config.MirakurunConfig
+	for _, mirakurunUrl := range *mirakurunUrls {
+		targets = append(targets, config.MirakurunConfig{URL: mirakurunUrl})
+	}
+	return targets
+}
+
+// newGeneration creates the collectors of targets following settings and starts their background work.
+func newGeneration(targets []config.MirakurunConfig, settings collector.Settings, clientOptions clientOptions, logger *slog.Logger, exporterGatherers ...prometheus.Gatherer) (*generation, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var mirakurunCollectors []*collector.MirakurunCollector
+	instances := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		// Instances are told apart by their label, which leaves out the credentials.
+		instance := mirakurun.InstanceURL(target.URL)
+		if instances[instance] {
+			cancel()
+			return nil, fmt.Errorf("duplicate Mirakurun URL %s", instance)
+		}
+		instances[instance] = true
+		client, err := mirakurun.NewClientWithOptions(target.URL, append(clientOptions.instance(target.HasAuth()), target.ClientOptions()...)...)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to create client of %s: %w", mirakurun.RedactURL(target.URL), err)
+		}
+
+		mirakurunCollector, err := collector.NewMirakurunCollector(client, settings, logger)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to create collector of %s: %w", client.URL, err)
+		}
+		mirakurunCollector.Start(ctx)
+		mirakurunCollectors = append(mirakurunCollectors, mirakurunCollector)
+		logger.Info("Scraping Mirakurun", "url", client.URL)
+	}
+
+	g := &generation{}
+	if *mirakurunPollInterval > 0 {
+		var pollers []*collector.Poller
+		for _, mirakurunCollector := range mirakurunCollectors {
+			poller := collector.NewPoller(mirakurunCollector, *mirakurunPollInterval, logger)
+			go poller.Run(ctx)
+			pollers = append(pollers, poller)
+		}
+		g.metrics = collector.PollerHandler(pollers, logger, exporterGatherers...)
+		logger.Info("Polling Mirakurun in the background", "interval", *mirakurunPollInterval)
+	} else {
+		g.metrics = collector.MetricsHandler(mirakurunCollectors, logger, exporterGatherers...)
+	}
+
+	prober, err := collector.NewProber(func(target string) (*mirakurun.Client, error) {
+		return mirakurun.NewClientWithOptions(target, clientOptions.common...)
+	}, *probeAllowedTargets, probeModules, collector.ProbeTargetLimits{
+		MaxTargets:  *probeMaxTargets,
+		IdleTimeout: *probeTargetIdleTimeout,
+	}, settings, logger)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to create prober: %w", err)
+	}
+	g.probe = collector.ProbeHandler(prober, logger)
+
+	g.close = func() {
+		cancel()
+		prober.Close()
+	}
+	return g, nil
+}
+
+func record(logger *slog.Logger) error {
+	clientOptions, err := mirakurunClientOptions()
+	if err != nil {
+		return err
+	}
+	if len(*mirakurunUrls) != 1 {
+		return fmt.Errorf("record needs exactly one --mirakurun.url, got %d", len(*mirakurunUrls))
+	}
+	client, err := mirakurun.NewClientWithOptions((*mirakurunUrls)[0], clientOptions.instance(false)...)
+	if err != nil {
+		return err
+	}
+
+	opts := fixture.RecordOptions{
+		RedactClientIPs:  *recordRedactClientIPs,
+		RedactProcessEnv: *recordRedactProcessEnv,
+	}
+	return fixture.Record(context.Background(), client, *recordDir, opts, logger)
+}
+
+// clientOptions are the options of the Mirakurun clients from the --mirakurun.* flags.
+type clientOptions struct {
+	// common are the options of every client, including those of /probe targets.
+	common []mirakurun.Option
+	// credentials are the headers and TLS client certificate, and auth the authentication. They are only
+	// sent to the configured instances so that /probe cannot hand them to another host.
+	credentials []mirakurun.Option
+	auth        []mirakurun.Option
+}
+
+// instance returns the options of the client of a configured instance. The authentication is left out
+// when the instance has its own.
+func (o clientOptions) instance(ownAuth bool) []mirakurun.Option {
+	opts := append(slices.Clone(o.common), o.credentials...)
+	if !ownAuth {
+		opts = append(opts, o.auth...)
+	}
+	return opts
+}
+
+func mirakurunClientOptions() (clientOptions, error) {
+	opts := []mirakurun.Option{
+		mirakurun.WithTimeout(time.Duration(*mirakurunRequestTimeout) * time.Second),
+		mirakurun.WithUserAgent(*mirakurunUserAgent),
+		mirakurun.WithMaxResponseSize(int64(*mirakurunMaxResponseSize)),
+	}
+
+	if *mirakurunStrictSchema {
+		opts = append(opts, mirakurun.WithStrictSchema())
+	}
+
+	if *mirakurunRetryMax > 0 {
+		opts = append(opts, mirakurun.WithRetry(*mirakurunRetryMax, *mirakurunRetryMinBackoff, *mirakurunRetryMaxBackoff))
+	}
+
+	for endpoint, value := range *mirakurunCacheTTLs {
+		ttl, err := time.ParseDuration(value)
+		if err != nil {
+			return clientOptions{}, fmt.Errorf("invalid cache ttl for %s: %w", endpoint, err)
+		}
+		opts = append(opts, mirakurun.WithCacheTTL(endpoint, ttl))
+	}
+	if len(*mirakurunCacheTTLs) > 0 {
+		opts = append(opts, mirakurun.WithCacheMaxStale(*mirakurunCacheMaxStale))
+	}
+
+	var credentials []mirakurun.Option
+	for _, header := range *mirakurunHeaders {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok {
+			return clientOptions{}, fmt.Errorf("invalid header %q: expected \"Name: value\"", header)
+		}
+		credentials = append(credentials, mirakurun.WithHeader(strings.TrimSpace(name), strings.TrimSpace(value)))
+	}
+
+	var auth []mirakurun.Option
+	if *mirakurunBasicAuthUser != "" || *mirakurunBasicAuthPass != "" {
+		auth = append(auth, mirakurun.WithBasicAuthPasswordFile(*mirakurunBasicAuthUser, *mirakurunBasicAuthPass))
+	}
+	if *mirakurunBearerToken != "" {
+		auth = append(auth, mirakurun.WithBearerTokenFile(*mirakurunBearerToken))
+	}
+
+	// The client certificate replaces the TLS config of the configured instances only.
+	tlsConfig := mirakurun.TLSConfig{
+		CAFile:             *mirakurunTLSCAFile,
+		ServerName:         *mirakurunTLSServerName,
+		InsecureSkipVerify: *mirakurunTLSInsecure,
+	}
+	if tlsConfig != (mirakurun.TLSConfig{}) {
+		opts = append(opts, mirakurun.WithTLSConfig(tlsConfig))
+	}
+	if *mirakurunTLSCertFile != "" || *mirakurunTLSKeyFile != "" {
+		tlsConfig.CertFile = *mirakurunTLSCertFile
+		tlsConfig.KeyFile = *mirakurunTLSKeyFile
+		credentials = append(credentials, mirakurun.WithTLSConfig(tlsConfig))
+	}
+
+	return clientOptions{common: opts, credentials: credentials, auth: auth}, nil
 }
@@ -0,0 +1,196 @@
+package mirakurun
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var cacheAgeDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(metricsNamespace, "", "cache_age_seconds"),
+	"Age of the cached Mirakurun API response served for an endpoint",
+	[]string{"endpoint"},
+	nil,
+)
+
+// cacheableEndpoints are the endpoints accepted by WithCacheTTL.
+var cacheableEndpoints = []string{
+	"status",
+	"version",
+	"tuners",
+	"channels",
+	"services",
+	"programs",
+	"events",
+	"log",
+	"jobs",
+	"config/server",
+	"config/tuners",
+	"config/channels",
+}
+
+// responseCache keeps decoded responses per endpoint.
+// An endpoint is cached only when it has a positive TTL. An expired entry keeps being served for
+// up to maxStale while it is refreshed in the background, and as long as refreshing it fails.
+type responseCache struct {
+	mu       sync.Mutex
+	ttls     map[string]time.Duration
+	maxStale time.Duration
+	entries  map[cacheKey]*cacheEntry
+	now      func() time.Time
+	// refreshTimeout bounds a background refresh, e.g. when the client has no request timeout.
+	refreshTimeout time.Duration
+}
+
+// cacheRefreshTimeout is the default refreshTimeout.
+const cacheRefreshTimeout = time.Minute
+
+// cacheKey distinguishes the representations of an endpoint, e.g. the programs
+// and the per-service program counts both read from /api/programs.
+type cacheKey struct {
+	endpoint string
+	kind     reflect.Type
+}
+
+type cacheEntry struct {
+	value     any
+	fetchedAt time.Time
+	// refreshing is set while the entry is refreshed in the background.
+	refreshing bool
+}
+
+func newResponseCache(ttls map[string]time.Duration, maxStale time.Duration) *responseCache {
+	return &responseCache{
+		ttls:     ttls,
+		maxStale: maxStale,
+		entries:  make(map[cacheKey]*cacheEntry),
+		now:      time.Now,
+
+		refreshTimeout: cacheRefreshTimeout,
+	}
+}
+
+// WithCacheTTL caches the responses of endpoint for ttl. endpoint is the API path without
+// the /api/ prefix, e.g. "programs" for /api/programs. A ttl of 0 disables caching.
+func WithCacheTTL(endpoint string, ttl time.Duration) Option {
+	return func(cfg *clientConfig) error {
+		endpoint = endpointOf(endpoint)
+		if !slices.Contains(cacheableEndpoints, endpoint) {
+			return fmt.Errorf("unknown cache endpoint %q, expected one of %s", endpoint, strings.Join(cacheableEndpoints, ", "))
+		}
+		if ttl < 0 {
+			return fmt.Errorf("cache ttl of %s must not be negative", endpoint)
+		}
+		cfg.cacheTTLs[endpoint] = ttl
+		return nil
+	}
+}
+
+// WithCacheMaxStale serves an expired cached response for up to maxStale while it is refreshed
+// in the background, and as long as refreshing it fails.
+func WithCacheMaxStale(maxStale time.Duration) Option {
+	return func(cfg *clientConfig) error {
+		if maxStale < 0 {
+			return fmt.Errorf("cache max stale must not be negative")
+		}
+		cfg.cacheMaxStale = maxStale
+		return nil
+	}
+}
+
+func endpointOf(path string) string {
+	return strings.TrimPrefix(path, "/api/")
+}
+
+// cached returns the cached response of path if it is fresh, otherwise it calls fetch and caches the result.
+// A stale response is returned right away while fetch refreshes it in the background.
+func cached[T any](ctx context.Context, c *Client, path string, logger *slog.Logger, fetch func(ctx context.Context) (*T, error)) (*T, error) {
+	cache := c.cache
+	endpoint := endpointOf(path)
+	ttl := cache.ttls[endpoint]
+	if ttl <= 0 {
+		return fetch(ctx)
+	}
+
+	key := cacheKey{endpoint: endpoint, kind: reflect.TypeFor[T]()}
+	now := cache.now()
+	cache.mu.Lock()
+	if entry := cache.entries[key]; entry != nil {
+		age := now.Sub(entry.fetchedAt)
+		if age < ttl+cache.maxStale {
+			if age >= ttl && !entry.refreshing {
+				entry.refreshing = true
+				go refresh(context.WithoutCancel(ctx), cache, key, logger, fetch)
+			}
+			value := entry.value.(*T)
+			cache.mu.Unlock()
+			return value, nil
+		}
+	}
+	cache.mu.Unlock()
+
+	value, err := fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.mu.Lock()
+	cache.entries[key] = &cacheEntry{value: value, fetchedAt: cache.now()}
+	cache.mu.Unlock()
+
+	return value, nil
+}
+
+// refresh replaces the stale entry of key with a new response. When fetch fails or does not complete
+// within the refresh timeout, the stale entry keeps being served and the next request retries.
+func refresh[T any](ctx context.Context, cache *responseCache, key cacheKey, logger *slog.Logger, fetch func(ctx context.Context) (*T, error)) {
+	ctx, cancel := context.WithTimeout(ctx, cache.refreshTimeout)
+	value, err := fetch(ctx)
+	cancel()
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if err != nil {
+		if entry := cache.entries[key]; entry != nil {
+			entry.refreshing = false
+			logger.Warn("serving stale mirakurun response", "endpoint", key.endpoint, "age_seconds", cache.now().Sub(entry.fetchedAt).Seconds(), "err", err)
+		}
+		return
+	}
+	cache.entries[key] = &cacheEntry{value: value, fetchedAt: cache.now()}
+}
+
+func (cache *responseCache) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cacheAgeDesc
+}
+
+func (cache *responseCache) Collect(ch chan<- prometheus.Metric) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	// Report the most recently fetched representation of each endpoint.
+	fetchedAt := make(map[string]time.Time)
+	for key, entry := range cache.entries {
+		if entry.fetchedAt.After(fetchedAt[key.endpoint]) {
+			fetchedAt[key.endpoint] = entry.fetchedAt
+		}
+	}
+
+	now := cache.now()
+	for endpoint, at := range fetchedAt {
+		ch <- prometheus.MustNewConstMetric(
+			cacheAgeDesc,
+			prometheus.GaugeValue,
+			now.Sub(at).Seconds(),
+			endpoint,
+		)
+	}
+}
@@ -0,0 +1,224 @@
+package mirakurun
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (clock *fakeClock) Now() time.Time {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	return clock.now
+}
+
+func (clock *fakeClock) Add(d time.Duration) {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	clock.now = clock.now.Add(d)
+}
+
+func newCacheTestServer(t *testing.T) (*httptest.Server, *atomic.Int32, *atomic.Bool) {
+	t.Helper()
+
+	var calls atomic.Int32
+	var failing atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"current":"4.0.0","latest":"4.0.1"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &calls, &failing
+}
+
+func TestClient_Cache(t *testing.T) {
+	srv, calls, failing := newCacheTestServer(t)
+
+	c, err := NewClientWithOptions(srv.URL, WithCacheTTL("version", time.Minute), WithCacheMaxStale(time.Minute))
+	require.NoError(t, err)
+	clock := &fakeClock{now: time.Unix(1749000000, 0)}
+	c.cache.now = clock.Now
+
+	ctx := context.Background()
+	logger := slog.Default()
+
+	first, err := c.GetVersion(ctx, logger)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), calls.Load())
+
+	// Fresh entries are served from the cache.
+	clock.Add(30 * time.Second)
+	second, err := c.GetVersion(ctx, logger)
+	require.NoError(t, err)
+	assert.Same(t, first, second)
+	assert.Equal(t, int32(1), calls.Load())
+
+	// Stale entries are served while they are refreshed in the background.
+	clock.Add(time.Minute)
+	third, err := c.GetVersion(ctx, logger)
+	require.NoError(t, err)
+	assert.Same(t, first, third)
+	assert.Eventually(t, func() bool { return calls.Load() == 2 }, time.Second, 10*time.Millisecond)
+	var fourth *VersionResponse
+	assert.Eventually(t, func() bool {
+		fourth, err = c.GetVersion(ctx, logger)
+		return err == nil && fourth != first
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(2), calls.Load())
+
+	// A stale entry keeps being served when refreshing it fails.
+	failing.Store(true)
+	clock.Add(90 * time.Second)
+	fifth, err := c.GetVersion(ctx, logger)
+	require.NoError(t, err)
+	assert.Same(t, fourth, fifth)
+	assert.Eventually(t, func() bool { return calls.Load() == 3 }, time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool {
+		c.cache.mu.Lock()
+		defer c.cache.mu.Unlock()
+		for _, entry := range c.cache.entries {
+			return !entry.refreshing
+		}
+		return false
+	}, time.Second, 10*time.Millisecond)
+	sixth, err := c.GetVersion(ctx, logger)
+	require.NoError(t, err)
+	assert.Same(t, fourth, sixth)
+
+	// Entries older than ttl + max stale are not served anymore.
+	clock.Add(time.Minute)
+	assert.Eventually(t, func() bool {
+		c.cache.mu.Lock()
+		defer c.cache.mu.Unlock()
+		for _, entry := range c.cache.entries {
+			return !entry.refreshing
+		}
+		return false
+	}, time.Second, 10*time.Millisecond)
+	_, err = c.GetVersion(ctx, logger)
+	require.Error(t, err)
+}
+
+func TestClient_CacheRefreshIsShared(t *testing.T) {
+	srv, calls, _ := newCacheTestServer(t)
+
+	c, err := NewClientWithOptions(srv.URL, WithCacheTTL("version", time.Minute), WithCacheMaxStale(time.Minute))
+	require.NoError(t, err)
+	clock := &fakeClock{now: time.Unix(1749000000, 0)}
+	c.cache.now = clock.Now
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err = c.GetVersion(ctx, slog.Default())
+	require.NoError(t, err)
+
+	// Requests served from a stale entry start a single refresh, which outlives their context.
+	clock.Add(90 * time.Second)
+	for i := 0; i < 3; i++ {
+		_, err := c.GetVersion(ctx, slog.Default())
+		require.NoError(t, err)
+	}
+	cancel()
+	assert.Eventually(t, func() bool { return calls.Load() == 2 }, time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestClient_CacheRefreshTimeout(t *testing.T) {
+	var hanging atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hanging.Load() {
+			<-r.Context().Done()
+			return
+		}
+		_, _ = w.Write([]byte(`{"current":"4.0.0","latest":"4.0.1"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	// The http.Client has no timeout, so only the refresh timeout ends a hung refresh.
+	c, err := NewClientWithOptions(srv.URL, WithHTTPClient(&http.Client{}), WithCacheTTL("version", time.Minute), WithCacheMaxStale(time.Hour))
+	require.NoError(t, err)
+	clock := &fakeClock{now: time.Unix(1749000000, 0)}
+	c.cache.now = clock.Now
+	c.cache.refreshTimeout = 50 * time.Millisecond
+
+	_, err = c.GetVersion(context.Background(), slog.Default())
+	require.NoError(t, err)
+
+	hanging.Store(true)
+	clock.Add(90 * time.Second)
+	_, err = c.GetVersion(context.Background(), slog.Default())
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		c.cache.mu.Lock()
+		defer c.cache.mu.Unlock()
+		for _, entry := range c.cache.entries {
+			return !entry.refreshing
+		}
+		return false
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestClient_CacheDisabledByDefault(t *testing.T) {
+	srv, calls, _ := newCacheTestServer(t)
+
+	c, err := NewClientWithOptions(srv.URL, WithCacheTTL("programs", time.Minute))
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err := c.GetVersion(context.Background(), slog.Default())
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestClient_CacheAgeMetric(t *testing.T) {
+	srv, _, _ := newCacheTestServer(t)
+
+	c, err := NewClientWithOptions(srv.URL, WithCacheTTL("/api/version", time.Minute))
+	require.NoError(t, err)
+	clock := &fakeClock{now: time.Unix(1749000000, 0)}
+	c.cache.now = clock.Now
+
+	_, err = c.GetVersion(context.Background(), slog.Default())
+	require.NoError(t, err)
+	clock.Add(42 * time.Second)
+
+	expected := `
+# HELP mirakurun_exporter_cache_age_seconds Age of the cached Mirakurun API response served for an endpoint
+# TYPE mirakurun_exporter_cache_age_seconds gauge
+mirakurun_exporter_cache_age_seconds{endpoint="version"} 42
+`
+	require.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(expected), "mirakurun_exporter_cache_age_seconds"))
+}
+
+func TestWithCacheTTL_Invalid(t *testing.T) {
+	_, err := NewClientWithOptions("http://localhost:40772", WithCacheTTL("programs", -time.Second))
+	require.Error(t, err)
+
+	_, err = NewClientWithOptions("http://localhost:40772", WithCacheMaxStale(-time.Second))
+	require.Error(t, err)
+
+	_, err = NewClientWithOptions("http://localhost:40772", WithCacheTTL("program", time.Minute))
+	require.ErrorContains(t, err, `unknown cache endpoint "program"`)
+}
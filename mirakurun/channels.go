@@ -2,12 +2,13 @@ package mirakurun
 
 import (
 	"context"
-	"fmt"
 	"log/slog"
-	"net/http"
+	"net/url"
 )
 
-type ChannelsResponse []struct {
+type ChannelsResponse []Channel
+
+type Channel struct {
 	Type      string           `json:"type"`
 	Channel   string           `json:"channel"`
 	Name      string           `json:"name"`
@@ -31,19 +32,22 @@ type ChannelService struct {
 }
 
 func (c *Client) GetChannels(ctx context.Context, logger *slog.Logger) (*ChannelsResponse, error) {
-	resp, err := c.request(ctx, "GET", "/api/channels", nil, logger)
-	if err != nil {
-		return nil, err
-	}
+	return get[ChannelsResponse](ctx, c, "/api/channels", logger)
+}
+
+// GetChannelsByType returns the channels of a type such as "GR", "BS", "CS" or "SKY".
+func (c *Client) GetChannelsByType(ctx context.Context, channelType string, logger *slog.Logger) (*ChannelsResponse, error) {
+	return get[ChannelsResponse](ctx, c, "/api/channels/"+url.PathEscape(channelType), logger)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("request failed with status code: %d", resp.StatusCode)
-	}
+func (c *Client) GetChannel(ctx context.Context, channelType string, channel string, logger *slog.Logger) (*Channel, error) {
+	return get[Channel](ctx, c, channelPath(channelType, channel), logger)
+}
 
-	var channels ChannelsResponse
-	if err := decodeBody(resp, &channels); err != nil {
-		return nil, err
-	}
+func (c *Client) GetChannelServices(ctx context.Context, channelType string, channel string, logger *slog.Logger) (*ServicesResponse, error) {
+	return get[ServicesResponse](ctx, c, channelPath(channelType, channel)+"/services", logger)
+}
 
-	return &channels, nil
+func channelPath(channelType string, channel string) string {
+	return "/api/channels/" + url.PathEscape(channelType) + "/" + url.PathEscape(channel)
 }
@@ -239,3 +239,71 @@ func TestGetChannels(t *testing.T) {
 		t.Fatalf("channels mismatch (-want +got):\n%s", diff)
 	}
 }
+
+func TestGetChannelsByType(t *testing.T) {
+	c := newFixtureClient(t, "/api/channels/GR", "channels_GR.json")
+
+	channels, err := c.GetChannelsByType(context.Background(), "GR", slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*channels) != 1 {
+		t.Fatalf("expected 1 channel, got %d", len(*channels))
+	}
+	if got := (*channels)[0]; got.Type != "GR" || got.Channel != "T27" || len(got.Services) != 3 {
+		t.Fatalf("unexpected channel: %+v", got)
+	}
+}
+
+func TestGetChannel(t *testing.T) {
+	c := newFixtureClient(t, "/api/channels/GR/T27", "channels_GR_T27.json")
+
+	channel, err := c.GetChannel(context.Background(), "GR", "T27", slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Channel{
+		Type:    "GR",
+		Channel: "T27",
+		Name:    "NHK総合・東京",
+		Services: []ChannelService{
+			{Id: 3273601024, ServiceId: 1024, NetworkId: 32736, Name: "ＮＨＫ総合１・東京", Type: 1},
+			{Id: 3273601025, ServiceId: 1025, NetworkId: 32736, Name: "ＮＨＫ総合２・東京", Type: 1},
+			{Id: 3273601408, ServiceId: 1408, NetworkId: 32736, Name: "ＮＨＫ携帯Ｇ・東京", Type: 192},
+		},
+	}
+
+	if diff := cmp.Diff(want, channel); diff != "" {
+		t.Fatalf("channel mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGetChannelServices(t *testing.T) {
+	c := newFixtureClient(t, "/api/channels/GR/T27/services", "channels_GR_T27_services.json")
+
+	services, err := c.GetChannelServices(context.Background(), "GR", "T27", slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &ServicesResponse{
+		{
+			ID:                 3273601024,
+			ServiceID:          1024,
+			NetworkID:          32736,
+			Name:               "ＮＨＫ総合１・東京",
+			Type:               1,
+			HasLogoData:        true,
+			RemoteControlKeyID: 1,
+			EpgReady:           true,
+			EpgUpdatedAt:       1749000000000,
+			Channel:            ServiceChannel{Type: "GR", Channel: "T27"},
+		},
+	}
+
+	if diff := cmp.Diff(want, services); diff != "" {
+		t.Fatalf("services mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -3,60 +3,339 @@ package mirakurun
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// unixSocketBaseURL is the placeholder host used for requests sent over a Unix domain socket.
+// The transport ignores it and always dials the socket.
+const unixSocketBaseURL = "http://unix"
+
 type Client struct {
+	// URL is the InstanceURL of the Mirakurun URL, for labels and logs.
 	URL string
 
-	httpClient *http.Client
+	baseURL     string
+	httpClient  *http.Client
+	headers     http.Header
+	userAgent   string
+	basicAuth   *basicAuth
+	bearerToken string
+	retry       retryPolicy
+	cache       *responseCache
+	metrics     *clientMetrics
+
+	maxResponseSize int64
+
+	// streamClient is httpClient without the request timeout, for long-lived streams.
+	streamClient  *http.Client
+	streamBackoff retryPolicy
+
+	strictSchema bool
+	schemaReport *schemaReport
 }
 
+// RedactURL removes the userinfo, e.g. basic auth credentials, from a Mirakurun URL so that it can
+// be shown. A string that is not a URL is returned unchanged.
+func RedactURL(mirakurunUrl string) string {
+	u, err := url.Parse(mirakurunUrl)
+	if err != nil || u.User == nil {
+		return mirakurunUrl
+	}
+	u.User = nil
+	return u.String()
+}
+
+// InstanceURL returns RedactURL of a Mirakurun URL without the case of its scheme and host or a trailing
+// "/", so that the URLs of one instance, e.g. with different credentials, return the same URL.
+func InstanceURL(mirakurunUrl string) string {
+	u, err := url.Parse(RedactURL(mirakurunUrl))
+	if err != nil {
+		return mirakurunUrl
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = ""
+	return u.String()
+}
+
+// NewClient creates a Mirakurun API client with a request timeout in seconds.
+// It is a shorthand for NewClientWithOptions with WithTimeout.
 func NewClient(mirakurunUrl string, requestTimeout int) (*Client, error) {
+	return NewClientWithOptions(mirakurunUrl, WithTimeout(time.Duration(requestTimeout)*time.Second))
+}
+
+// NewClientWithOptions creates a Mirakurun API client.
+// mirakurunUrl is either an HTTP URL such as http://localhost:40772 or a Unix domain socket
+// such as unix:///var/run/mirakurun.sock (http+unix:// is accepted as well).
+func NewClientWithOptions(mirakurunUrl string, opts ...Option) (*Client, error) {
 	if mirakurunUrl == "" {
 		return nil, fmt.Errorf("mirakurun url is empty")
 	}
 
-	if _, err := url.ParseRequestURI(mirakurunUrl); err != nil {
+	u, err := url.ParseRequestURI(mirakurunUrl)
+	if err != nil {
 		return nil, fmt.Errorf("mirakurun url is invalid: %w", err)
 	}
 
+	cfg := &clientConfig{
+		timeout:   defaultRequestTimeout,
+		headers:   make(http.Header),
+		userAgent: defaultUserAgent,
+		cacheTTLs: make(map[string]time.Duration),
+
+		streamBackoff: defaultStreamBackoff,
+	}
+	for _, opt := range opts {
+		if err := opt(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.basicAuth != nil && cfg.bearerToken != "" {
+		return nil, fmt.Errorf("basic auth and bearer token cannot be used at the same time")
+	}
+
 	client := &Client{
-		URL:        mirakurunUrl,
-		httpClient: &http.Client{Timeout: time.Duration(requestTimeout) * time.Second},
+		URL:         InstanceURL(mirakurunUrl),
+		baseURL:     mirakurunUrl,
+		headers:     cfg.headers,
+		userAgent:   cfg.userAgent,
+		basicAuth:   cfg.basicAuth,
+		bearerToken: cfg.bearerToken,
+		retry:       cfg.retry,
+		cache:       newResponseCache(cfg.cacheTTLs, cfg.cacheMaxStale),
+		metrics:     newClientMetrics(),
+
+		maxResponseSize: cfg.maxResponseSize,
+
+		streamBackoff: cfg.streamBackoff,
+
+		strictSchema: cfg.strictSchema,
+		schemaReport: newSchemaReport(),
 	}
 
+	isUnixSocket := isUnixSocketScheme(u.Scheme)
+	if isUnixSocket {
+		if u.Path == "" {
+			return nil, fmt.Errorf("mirakurun url is invalid: socket path is empty")
+		}
+		client.baseURL = unixSocketBaseURL
+	}
+
+	if cfg.httpClient != nil {
+		if isUnixSocket || cfg.tlsConfig != nil {
+			return nil, fmt.Errorf("custom http client cannot be combined with TLS config or a Unix domain socket")
+		}
+		client.httpClient = cfg.httpClient
+		streamClient := *cfg.httpClient
+		streamClient.Timeout = 0
+		client.streamClient = &streamClient
+		return client, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if isUnixSocket {
+		transport.Proxy = nil
+		transport.DialContext = unixSocketDialer(u.Path)
+	}
+	if cfg.tlsConfig != nil {
+		tlsConfig, err := cfg.tlsConfig.build()
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	client.httpClient = &http.Client{Timeout: cfg.timeout, Transport: transport}
+	client.streamClient = &http.Client{Transport: transport}
+
 	return client, nil
 }
 
+func isUnixSocketScheme(scheme string) bool {
+	return scheme == "unix" || scheme == "http+unix"
+}
+
+func unixSocketDialer(socketPath string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, "unix", socketPath)
+	}
+}
+
+// get fetches path and decodes the JSON response, going through the response cache.
+func get[T any](ctx context.Context, c *Client, path string, logger *slog.Logger) (*T, error) {
+	return cached(ctx, c, path, logger, func(ctx context.Context) (*T, error) {
+		resp, err := c.request(ctx, http.MethodGet, path, nil, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		var v T
+		if err := c.decodeBody(resp, &v, logger); err != nil {
+			return nil, err
+		}
+
+		return &v, nil
+	})
+}
+
+// GetRaw returns the body of path, e.g. "/api/status", as Mirakurun sent it.
+// It is not cached, and the body is limited to the maximum response size.
+func (c *Client) GetRaw(ctx context.Context, path string, logger *slog.Logger) ([]byte, error) {
+	resp, err := c.request(ctx, http.MethodGet, path, nil, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	var body []byte
+	err = c.read(resp, func(r io.Reader) error {
+		body, err = io.ReadAll(r)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
+
 func (c *Client) request(ctx context.Context, method string, path string, body io.Reader, logger *slog.Logger) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := c.doRequest(ctx, method, path, body, logger)
+		if err == nil {
+			return resp, nil
+		}
+
+		if !isRetryable(err) || !isIdempotent(method) || ctx.Err() != nil {
+			return nil, err
+		}
+		if attempt >= c.retry.maxRetries {
+			if attempt > 0 {
+				c.metrics.retriesExhausted.WithLabelValues(routeOf(path)).Inc()
+			}
+			return nil, err
+		}
+
+		wait := c.retry.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			c.metrics.retriesExhausted.WithLabelValues(routeOf(path)).Inc()
+			return nil, err
+		}
+
+		c.metrics.retries.WithLabelValues(routeOf(path)).Inc()
+		logger.Debug("retrying mirakurun request", "method", method, "path", path, "attempt", attempt+1, "wait_seconds", wait.Seconds(), "err", err)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
+
+// doRequest sends a single request.
+func (c *Client) doRequest(ctx context.Context, method string, path string, body io.Reader, logger *slog.Logger) (*http.Response, error) {
+	return c.send(ctx, c.httpClient, method, path, body, logger)
+}
+
+// send sends a single request with httpClient.
+func (c *Client) send(ctx context.Context, httpClient *http.Client, method string, path string, body io.Reader, logger *slog.Logger) (*http.Response, error) {
 	begin := time.Now()
-	req, err := http.NewRequestWithContext(ctx, method, c.URL+path, body)
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
+	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	route := routeOf(path)
+	resp, err := httpClient.Do(req)
+	duration := time.Since(begin)
+	c.metrics.requestDuration.WithLabelValues(route).Observe(duration.Seconds())
 	if err != nil {
-		return nil, fmt.Errorf("failed to do request: %w", err)
+		c.metrics.requests.WithLabelValues(route, "error").Inc()
+		return nil, newTransportError(path, err)
 	}
 
-	duration := time.Since(begin)
-	logger.Debug("mirakurun request", "method", method, "path", path, "duration_seconds", duration.Seconds())
+	c.metrics.requests.WithLabelValues(route, strconv.Itoa(resp.StatusCode)).Inc()
+	logger.Debug("mirakurun request", "method", method, "path", path, "status_code", resp.StatusCode, "duration_seconds", duration.Seconds())
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("request failed with status code: %d", resp.StatusCode)
+		err := newStatusError(path, resp.StatusCode, resp.Body)
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainSize))
+		_ = resp.Body.Close()
+		return nil, err
 	}
 
 	return resp, nil
 }
 
-func decodeBody(resp *http.Response, v interface{}) error {
+func (c *Client) setHeaders(req *http.Request) {
+	for key, values := range c.headers {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	if c.basicAuth != nil {
+		req.SetBasicAuth(c.basicAuth.username, c.basicAuth.password)
+	} else if c.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.bearerToken)
+	}
+}
+
+func (c *Client) decodeBody(resp *http.Response, v interface{}, logger *slog.Logger) error {
+	if !c.strictSchema {
+		return c.decode(resp, func(decoder *json.Decoder) error {
+			return decoder.Decode(v)
+		})
+	}
+
+	var raw json.RawMessage
+	if err := c.decode(resp, func(decoder *json.Decoder) error {
+		return decoder.Decode(&raw)
+	}); err != nil {
+		return err
+	}
+
+	path := resp.Request.URL.Path
+	if err := json.Unmarshal(raw, v); err != nil {
+		return newDecodeError(path, 0, err)
+	}
+
+	drift, err := checkSchema(raw, reflect.TypeOf(v).Elem())
+	if err != nil {
+		logger.Debug("failed to check the schema of mirakurun response", "path", path, "err", err)
+		return nil
+	}
+	c.recordSchemaDrift(endpointOf(routeOf(path)), drift, logger)
+	return nil
+}
+
+// decode reads the JSON response body with fn and closes it.
+func (c *Client) decode(resp *http.Response, fn func(decoder *json.Decoder) error) error {
+	return c.read(resp, func(body io.Reader) error {
+		return fn(json.NewDecoder(body))
+	})
+}
+
+// read reads the response body with fn and closes it. The body is limited to the
+// maximum response size.
+func (c *Client) read(resp *http.Response, fn func(body io.Reader) error) error {
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
 		if err != nil {
@@ -65,12 +344,76 @@ func decodeBody(resp *http.Response, v interface{}) error {
 	}(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("request failed with status code: %d", resp.StatusCode)
+		return newStatusError(resp.Request.URL.Path, resp.StatusCode, resp.Body)
+	}
+
+	path := resp.Request.URL.Path
+	if c.maxResponseSize > 0 && resp.ContentLength > c.maxResponseSize {
+		return &ResponseTooLargeError{Path: path, Limit: c.maxResponseSize}
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
-		return fmt.Errorf("failed to decode response body: %w", err)
+	begin := time.Now()
+	body := &countingReader{r: resp.Body, limit: c.maxResponseSize}
+	err := fn(body)
+	route := routeOf(path)
+	c.metrics.decodeDuration.WithLabelValues(route).Observe(time.Since(begin).Seconds())
+	c.metrics.responseSize.WithLabelValues(route).Observe(float64(body.n))
+	if body.exceeded {
+		return &ResponseTooLargeError{Path: path, Limit: c.maxResponseSize}
+	}
+	if err != nil {
+		return newDecodeError(path, body.n, err)
+	}
+
+	return nil
+}
+
+// decodeArray decodes a JSON array one element at a time and passes each element to fn,
+// so that the whole array is never held in memory.
+func decodeArray[T any](decoder *json.Decoder, fn func(item *T) error) error {
+	if err := expectDelim(decoder, '['); err != nil {
+		return err
+	}
+	for decoder.More() {
+		var item T
+		if err := decoder.Decode(&item); err != nil {
+			return err
+		}
+		if err := fn(&item); err != nil {
+			return err
+		}
 	}
+	return expectDelim(decoder, ']')
+}
 
+func expectDelim(decoder *json.Decoder, want json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != want {
+		return fmt.Errorf("expected %q but got %v", want, token)
+	}
 	return nil
 }
+
+var errResponseTooLarge = errors.New("response too large")
+
+// countingReader counts the bytes read from r and fails once more than limit bytes are read.
+// A limit of 0 means no limit.
+type countingReader struct {
+	r        io.Reader
+	n        int64
+	limit    int64
+	exceeded bool
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	if r.limit > 0 && r.n > r.limit {
+		r.exceeded = true
+		return n, errResponseTooLarge
+	}
+	return n, err
+}
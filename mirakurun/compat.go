@@ -0,0 +1,84 @@
+package mirakurun
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a Mirakurun version. Pre-release and build suffixes such as -beta.18 are ignored.
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// ParseVersion parses a version such as "4.0.0-beta.18" as reported by /api/status and /api/version.
+func ParseVersion(s string) (Version, error) {
+	core, _, _ := strings.Cut(strings.TrimPrefix(s, "v"), "-")
+	core, _, _ = strings.Cut(core, "+")
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return Version{}, fmt.Errorf("invalid mirakurun version %q", s)
+	}
+
+	var numbers [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return Version{}, fmt.Errorf("invalid mirakurun version %q", s)
+		}
+		numbers[i] = n
+	}
+	return Version{Major: numbers[0], Minor: numbers[1], Patch: numbers[2]}, nil
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// AtLeast reports whether v is other or newer.
+func (v Version) AtLeast(other Version) bool {
+	if v.Major != other.Major {
+		return v.Major > other.Major
+	}
+	if v.Minor != other.Minor {
+		return v.Minor > other.Minor
+	}
+	return v.Patch >= other.Patch
+}
+
+// Feature is a part of the Mirakurun API that older versions lack.
+type Feature string
+
+const (
+	// FeatureJobs is the /api/jobs endpoint.
+	FeatureJobs Feature = "jobs"
+	// FeatureTimerAccuracy is timerAccuracy in /api/status.
+	FeatureTimerAccuracy Feature = "timer_accuracy"
+)
+
+// featureSince is the first version providing each feature.
+var featureSince = map[Feature]Version{
+	FeatureJobs:          {Major: 4},
+	FeatureTimerAccuracy: {Major: 3, Minor: 9},
+}
+
+// Supports reports whether Mirakurun of version v provides feature.
+func (v Version) Supports(feature Feature) bool {
+	since, ok := featureSince[feature]
+	return !ok || v.AtLeast(since)
+}
+
+// CompatibilityMode names the group of versions sharing the same API: "3.x" before 3.9,
+// "3.9" for 3.9 and later 3.x releases, and "4.x" from 4.0 on.
+func (v Version) CompatibilityMode() string {
+	switch {
+	case v.Major >= 4:
+		return "4.x"
+	case v.AtLeast(Version{Major: 3, Minor: 9}):
+		return "3.9"
+	default:
+		return "3.x"
+	}
+}
@@ -0,0 +1,59 @@
+package mirakurun
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    Version
+		wantErr bool
+	}{
+		{version: "4.0.0-beta.18", want: Version{Major: 4}},
+		{version: "3.9.0-rc.4", want: Version{Major: 3, Minor: 9}},
+		{version: "3.8.1", want: Version{Major: 3, Minor: 8, Patch: 1}},
+		{version: "v3.2.0+build", want: Version{Major: 3, Minor: 2}},
+		{version: "", wantErr: true},
+		{version: "4.0", wantErr: true},
+		{version: "4.x.0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			got, err := ParseVersion(tt.version)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestVersion_Compatibility(t *testing.T) {
+	tests := []struct {
+		version       Version
+		mode          string
+		jobs          bool
+		timerAccuracy bool
+	}{
+		{version: Version{Major: 3, Minor: 8, Patch: 1}, mode: "3.x", jobs: false, timerAccuracy: false},
+		{version: Version{Major: 3, Minor: 9}, mode: "3.9", jobs: false, timerAccuracy: true},
+		{version: Version{Major: 3, Minor: 10}, mode: "3.9", jobs: false, timerAccuracy: true},
+		{version: Version{Major: 4}, mode: "4.x", jobs: true, timerAccuracy: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version.String(), func(t *testing.T) {
+			assert.Equal(t, tt.mode, tt.version.CompatibilityMode())
+			assert.Equal(t, tt.jobs, tt.version.Supports(FeatureJobs))
+			assert.Equal(t, tt.timerAccuracy, tt.version.Supports(FeatureTimerAccuracy))
+			assert.True(t, tt.version.Supports(Feature("unknown")))
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package mirakurun
+
+import (
+	"context"
+	"log/slog"
+)
+
+// ServerConfig is the server.yml of Mirakurun.
+type ServerConfig struct {
+	Path                      string   `json:"path"`
+	Port                      int      `json:"port"`
+	Hostname                  string   `json:"hostname"`
+	DisableIPv6               bool     `json:"disableIPv6"`
+	LogLevel                  int      `json:"logLevel"`
+	MaxLogHistory             int      `json:"maxLogHistory"`
+	MaxBufferBytesBeforeReady int64    `json:"maxBufferBytesBeforeReady"`
+	EventEndTimeout           int      `json:"eventEndTimeout"`
+	ProgramGCInterval         int      `json:"programGCInterval"`
+	EpgGatheringInterval      int      `json:"epgGatheringInterval"`
+	EpgRetrievalTime          int      `json:"epgRetrievalTime"`
+	LogoDataInterval          int      `json:"logoDataInterval"`
+	DisableEITParsing         bool     `json:"disableEITParsing"`
+	DisableWebUI              bool     `json:"disableWebUI"`
+	AllowIPv4CidrRanges       []string `json:"allowIPv4CidrRanges"`
+	AllowIPv6CidrRanges       []string `json:"allowIPv6CidrRanges"`
+}
+
+// TunersConfig is the tuners.yml of Mirakurun.
+type TunersConfig []TunerConfig
+
+type TunerConfig struct {
+	Name                   string   `json:"name"`
+	Types                  []string `json:"types"`
+	Command                string   `json:"command"`
+	DvbDevicePath          string   `json:"dvbDevicePath"`
+	RemoteMirakurunHost    string   `json:"remoteMirakurunHost"`
+	RemoteMirakurunPort    int      `json:"remoteMirakurunPort"`
+	RemoteMirakurunDecoder bool     `json:"remoteMirakurunDecoder"`
+	Decoder                string   `json:"decoder"`
+	IsDisabled             bool     `json:"isDisabled"`
+}
+
+// ChannelsConfig is the channels.yml of Mirakurun.
+type ChannelsConfig []ChannelConfig
+
+type ChannelConfig struct {
+	Name        string                 `json:"name"`
+	Type        string                 `json:"type"`
+	Channel     string                 `json:"channel"`
+	ServiceID   int                    `json:"serviceId"`
+	TsmfRelTs   int                    `json:"tsmfRelTs"`
+	CommandVars map[string]interface{} `json:"commandVars"`
+	IsDisabled  bool                   `json:"isDisabled"`
+}
+
+func (c *Client) GetServerConfig(ctx context.Context, logger *slog.Logger) (*ServerConfig, error) {
+	return get[ServerConfig](ctx, c, "/api/config/server", logger)
+}
+
+func (c *Client) GetTunersConfig(ctx context.Context, logger *slog.Logger) (*TunersConfig, error) {
+	return get[TunersConfig](ctx, c, "/api/config/tuners", logger)
+}
+
+func (c *Client) GetChannelsConfig(ctx context.Context, logger *slog.Logger) (*ChannelsConfig, error) {
+	return get[ChannelsConfig](ctx, c, "/api/config/channels", logger)
+}
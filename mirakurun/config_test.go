@@ -0,0 +1,102 @@
+package mirakurun
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGetServerConfig(t *testing.T) {
+	c := newFixtureClient(t, "/api/config/server", "config_server.json")
+
+	config, err := c.GetServerConfig(context.Background(), slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &ServerConfig{
+		Path:                      "/var/run/mirakurun.sock",
+		Port:                      40772,
+		DisableIPv6:               true,
+		LogLevel:                  2,
+		MaxLogHistory:             1000,
+		MaxBufferBytesBeforeReady: 8388608,
+		EventEndTimeout:           1000,
+		ProgramGCInterval:         900,
+		EpgGatheringInterval:      1800000,
+		EpgRetrievalTime:          600000,
+		LogoDataInterval:          604800000,
+		AllowIPv4CidrRanges:       []string{"10.0.0.0/8", "127.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"},
+		AllowIPv6CidrRanges:       []string{"fc00::/7"},
+	}
+
+	if diff := cmp.Diff(want, config); diff != "" {
+		t.Fatalf("server config mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGetTunersConfig(t *testing.T) {
+	c := newFixtureClient(t, "/api/config/tuners", "config_tuners.json")
+
+	config, err := c.GetTunersConfig(context.Background(), slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &TunersConfig{
+		{
+			Name:    "Tuner (Terrestrial) #1",
+			Types:   []string{"GR"},
+			Command: "recisdb tune --device /dev/pt3video2 --channel <channel> -",
+			Decoder: "arib-b25-stream-test",
+		},
+		{
+			Name:    "Tuner (Satellite) #1",
+			Types:   []string{"BS", "CS"},
+			Command: "recisdb tune --device /dev/pt3video0 --channel <channel> -",
+			Decoder: "arib-b25-stream-test",
+		},
+		{
+			Name:                   "Remote",
+			Types:                  []string{"GR"},
+			RemoteMirakurunHost:    "192.168.1.20",
+			RemoteMirakurunPort:    40772,
+			RemoteMirakurunDecoder: true,
+			IsDisabled:             true,
+		},
+	}
+
+	if diff := cmp.Diff(want, config); diff != "" {
+		t.Fatalf("tuners config mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGetChannelsConfig(t *testing.T) {
+	c := newFixtureClient(t, "/api/config/channels", "config_channels.json")
+
+	config, err := c.GetChannelsConfig(context.Background(), slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &ChannelsConfig{
+		{
+			Name:        "NHK総合・東京",
+			Type:        "GR",
+			Channel:     "T27",
+			CommandVars: map[string]interface{}{"satellite": " "},
+		},
+		{
+			Name:        "BS15/TS0",
+			Type:        "BS",
+			Channel:     "BS15_0",
+			CommandVars: map[string]interface{}{"satellite": " --tsid 16625 "},
+		},
+	}
+
+	if diff := cmp.Diff(want, config); diff != "" {
+		t.Fatalf("channels config mismatch (-want +got):\n%s", diff)
+	}
+}
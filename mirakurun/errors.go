@@ -0,0 +1,159 @@
+package mirakurun
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+)
+
+// maxErrorBodySize is the maximum number of bytes of a response body kept in a StatusError.
+const maxErrorBodySize = 512
+
+// maxDrainSize is the maximum number of bytes of an error response read so that its connection can be
+// reused. Larger bodies are left unread and their connection is closed.
+const maxDrainSize = 64 << 10
+
+// ConnectionRefusedError is returned when Mirakurun refuses the connection, which usually means it is not running.
+type ConnectionRefusedError struct {
+	Path string
+	Err  error
+}
+
+func (e *ConnectionRefusedError) Error() string {
+	return fmt.Sprintf("connection refused: %s: %v", e.Path, e.Err)
+}
+
+func (e *ConnectionRefusedError) Unwrap() error {
+	return e.Err
+}
+
+// TimeoutError is returned when a request did not complete within the request timeout or the context deadline.
+type TimeoutError struct {
+	Path string
+	Err  error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("request timed out: %s: %v", e.Path, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// RequestError is returned for transport failures other than a refused connection or a timeout.
+type RequestError struct {
+	Path string
+	Err  error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("failed to do request: %s: %v", e.Path, e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// StatusError is returned when Mirakurun responds with a status code other than 200 OK.
+// Body holds the beginning of the response body.
+type StatusError struct {
+	Path       string
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("request failed with status code: %d: %s", e.StatusCode, e.Path)
+	}
+	return fmt.Sprintf("request failed with status code: %d: %s: %s", e.StatusCode, e.Path, e.Body)
+}
+
+// DecodeError is returned when a response body cannot be decoded as JSON.
+// Field is the JSON field that had an unexpected type, if known, and Offset is the byte offset where decoding failed.
+// For a truncated body, Offset is the number of bytes received.
+type DecodeError struct {
+	Path   string
+	Field  string
+	Offset int64
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("failed to decode response body: %s: field %q at offset %d: %v", e.Path, e.Field, e.Offset, e.Err)
+	}
+	return fmt.Sprintf("failed to decode response body: %s: at offset %d: %v", e.Path, e.Offset, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// ResponseTooLargeError is returned when a response body exceeds the size set by WithMaxResponseSize.
+type ResponseTooLargeError struct {
+	Path  string
+	Limit int64
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("response body exceeds %d bytes: %s", e.Limit, e.Path)
+}
+
+func newTransportError(path string, err error) error {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return &ConnectionRefusedError{Path: path, Err: err}
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return &TimeoutError{Path: path, Err: err}
+	default:
+		return &RequestError{Path: path, Err: err}
+	}
+}
+
+func newStatusError(path string, statusCode int, body io.Reader) error {
+	buf, _ := io.ReadAll(io.LimitReader(body, maxErrorBodySize))
+	return &StatusError{Path: path, StatusCode: statusCode, Body: string(buf)}
+}
+
+func newDecodeError(path string, offset int64, err error) error {
+	decodeErr := &DecodeError{Path: path, Offset: offset, Err: err}
+
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &typeErr):
+		decodeErr.Field = typeErr.Field
+		decodeErr.Offset = typeErr.Offset
+	case errors.As(err, &syntaxErr):
+		decodeErr.Offset = syntaxErr.Offset
+	}
+
+	return decodeErr
+}
+
+// isRetryable reports whether a failed request may succeed when sent again.
+func isRetryable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return isRetryableStatus(statusErr.StatusCode)
+	}
+
+	var connErr *ConnectionRefusedError
+	var timeoutErr *TimeoutError
+	var requestErr *RequestError
+	switch {
+	case errors.As(err, &connErr), errors.As(err, &timeoutErr):
+		return true
+	case errors.As(err, &requestErr):
+		return !errors.Is(err, context.Canceled)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,163 @@
+package mirakurun
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_ConnectionRefusedError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	c, err := NewClient("http://"+addr, 1)
+	require.NoError(t, err)
+
+	_, err = c.GetStatus(context.Background(), slog.Default())
+
+	var connErr *ConnectionRefusedError
+	require.ErrorAs(t, err, &connErr)
+	assert.Equal(t, "/api/status", connErr.Path)
+}
+
+func TestClient_TimeoutError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewClientWithOptions(srv.URL, WithTimeout(50*time.Millisecond))
+	require.NoError(t, err)
+
+	_, err = c.GetStatus(context.Background(), slog.Default())
+
+	var timeoutErr *TimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	assert.Equal(t, "/api/status", timeoutErr.Path)
+}
+
+func TestClient_StatusError(t *testing.T) {
+	body := strings.Repeat("x", 1000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, 1)
+	require.NoError(t, err)
+
+	_, err = c.GetTuners(context.Background(), slog.Default())
+
+	var statusErr *StatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, "/api/tuners", statusErr.Path)
+	assert.Equal(t, http.StatusInternalServerError, statusErr.StatusCode)
+	assert.Equal(t, body[:maxErrorBodySize], statusErr.Body)
+}
+
+func TestClient_StatusErrorLargeBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		chunk := []byte(strings.Repeat("x", 1024))
+		for r.Context().Err() == nil {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewClientWithOptions(srv.URL, WithTimeout(5*time.Second))
+	require.NoError(t, err)
+
+	// The endless body is not read to the end.
+	begin := time.Now()
+	_, err = c.GetTuners(context.Background(), slog.Default())
+	var statusErr *StatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Less(t, time.Since(begin), time.Second)
+}
+
+func TestClient_DecodeError(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantField  string
+		wantOffset int64
+	}{
+		{
+			name:       "type mismatch",
+			body:       `{"current": 4, "latest": "4.0.0"}`,
+			wantField:  "current",
+			wantOffset: 13,
+		},
+		{
+			name:       "syntax error",
+			body:       `{"current": "4.0.0",, "latest": "4.0.0"}`,
+			wantOffset: 21,
+		},
+		{
+			name:       "truncated",
+			body:       `{"current": "4.0.0", "lat`,
+			wantOffset: 25,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			c, err := NewClient(srv.URL, 1)
+			require.NoError(t, err)
+
+			_, err = c.GetVersion(context.Background(), slog.Default())
+
+			var decodeErr *DecodeError
+			require.ErrorAs(t, err, &decodeErr)
+			assert.Equal(t, "/api/version", decodeErr.Path)
+			assert.Equal(t, tt.wantField, decodeErr.Field)
+			assert.Equal(t, tt.wantOffset, decodeErr.Offset)
+		})
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "connection refused", err: &ConnectionRefusedError{Path: "/api/status"}, want: true},
+		{name: "timeout", err: &TimeoutError{Path: "/api/status"}, want: true},
+		{name: "request", err: &RequestError{Path: "/api/status", Err: errors.New("EOF")}, want: true},
+		{name: "canceled", err: &RequestError{Path: "/api/status", Err: context.Canceled}, want: false},
+		{name: "server error", err: &StatusError{StatusCode: http.StatusServiceUnavailable}, want: true},
+		{name: "too many requests", err: &StatusError{StatusCode: http.StatusTooManyRequests}, want: true},
+		{name: "not found", err: &StatusError{StatusCode: http.StatusNotFound}, want: false},
+		{name: "decode", err: &DecodeError{Path: "/api/status"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isRetryable(tt.err))
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package mirakurun
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"time"
+)
+
+type EventsResponse []Event
+
+// Event is a change of a tuner, service or program. Resource is one of "tuner", "service"
+// or "program" and Type is one of "create", "update" or "remove". Data is the changed
+// resource and Time is a Unix time in milliseconds.
+type Event struct {
+	Resource string          `json:"resource"`
+	Type     string          `json:"type"`
+	Data     json.RawMessage `json:"data"`
+	Time     int64           `json:"time"`
+}
+
+// EventTime returns the time the event occurred.
+func (e *Event) EventTime() time.Time {
+	return time.UnixMilli(e.Time)
+}
+
+// GetEvents returns the recent events kept by Mirakurun.
+func (c *Client) GetEvents(ctx context.Context, logger *slog.Logger) (*EventsResponse, error) {
+	return get[EventsResponse](ctx, c, "/api/events", logger)
+}
+
+// SubscribeEvents follows /api/events/stream and calls fn for each event until ctx is done.
+// The stream is reconnected with backoff when it fails or Mirakurun closes it, e.g. on restart,
+// so events occurring while disconnected are missed. It returns the error of ctx.
+func (c *Client) SubscribeEvents(ctx context.Context, fn func(event *Event), logger *slog.Logger) error {
+	return c.stream(ctx, "/api/events/stream", logger, func(body io.Reader) error {
+		return decodeArray(json.NewDecoder(body), func(event *Event) error {
+			fn(event)
+			return nil
+		})
+	})
+}
@@ -0,0 +1,131 @@
+package mirakurun
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetEvents(t *testing.T) {
+	c := newFixtureClient(t, "/api/events", "events.json")
+
+	events, err := c.GetEvents(context.Background(), slog.Default())
+	require.NoError(t, err)
+	require.Len(t, *events, 3)
+
+	tests := []struct {
+		resource  string
+		eventType string
+		time      time.Time
+	}{
+		{resource: "tuner", eventType: "update", time: time.UnixMilli(1749000000000)},
+		{resource: "service", eventType: "update", time: time.UnixMilli(1749000001000)},
+		{resource: "program", eventType: "create", time: time.UnixMilli(1749000002000)},
+	}
+	for i, tt := range tests {
+		event := (*events)[i]
+		assert.Equal(t, tt.resource, event.Resource)
+		assert.Equal(t, tt.eventType, event.Type)
+		assert.True(t, tt.time.Equal(event.EventTime()))
+	}
+
+	var tuner Tuner
+	require.NoError(t, json.Unmarshal((*events)[0].Data, &tuner))
+	assert.Equal(t, 1, tuner.Index)
+
+	var program Program
+	require.NoError(t, json.Unmarshal((*events)[2].Data, &program))
+	assert.Equal(t, 3023, program.EventID)
+}
+
+func TestSubscribeEvents(t *testing.T) {
+	var connections atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/events/stream" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("[\n"))
+		switch connections.Add(1) {
+		case 1:
+			// Mirakurun writes each event followed by a comma and closes the stream on restart.
+			_, _ = w.Write([]byte(`{"resource":"tuner","type":"update","data":{"index":1},"time":1749000000000}` + "\n,"))
+			_, _ = w.Write([]byte(`{"resource":"service","type":"create","data":{"id":3273601024},"time":1749000001000}` + "\n,"))
+		case 2:
+			_, _ = w.Write([]byte(`{"resource":"program","type":"remove","data":{"id":327360102403001},"time":1749000002000}` + "\n,"))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewClientWithOptions(srv.URL, WithStreamBackoff(10*time.Millisecond, 20*time.Millisecond))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	received := make(chan Event, 10)
+	done := make(chan error)
+	go func() {
+		done <- c.SubscribeEvents(ctx, func(event *Event) {
+			received <- *event
+		}, slog.Default())
+	}()
+
+	var got []string
+	for len(got) < 3 {
+		select {
+		case event := <-received:
+			got = append(got, event.Resource+"/"+event.Type)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for events, got %v", got)
+		}
+	}
+	assert.Equal(t, []string{"tuner/update", "service/create", "program/remove"}, got)
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.metrics.streamConnected.WithLabelValues("/api/events/stream")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.metrics.streamReconnects.WithLabelValues("/api/events/stream")))
+
+	cancel()
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("SubscribeEvents did not return after cancel")
+	}
+	assert.Equal(t, 0.0, testutil.ToFloat64(c.metrics.streamConnected.WithLabelValues("/api/events/stream")))
+}
+
+func TestSubscribeEvents_Unreachable(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	c, err := NewClientWithOptions(srv.URL, WithStreamBackoff(time.Millisecond, 5*time.Millisecond))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	err = c.SubscribeEvents(ctx, func(event *Event) {
+		t.Errorf("unexpected event: %+v", event)
+	}, slog.Default())
+
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Greater(t, requests.Load(), int32(1))
+}
@@ -0,0 +1,247 @@
+// Package fixture records the responses of a Mirakurun to a fixture directory and serves them
+// back as a fake Mirakurun.
+//
+// Handler serves a fixture directory in the layout of test/mirakurun: the response of
+// /api/<path> is read from <path with "/" replaced by "_">.json, e.g. /api/tuners/1/process
+// from tuners_1_process.json, and /api/log from log.txt. The streams /api/events/stream and
+// /api/log/stream send the fixture of /api/events and /api/log and stay open.
+package fixture
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AllEndpoints is the path of a fault applied to every endpoint without a fault of its own.
+const AllEndpoints = "*"
+
+// Fault is an error injected into the responses of an endpoint.
+type Fault struct {
+	// Latency delays the response.
+	Latency time.Duration
+	// StatusCode, if not zero, replaces the response with a Mirakurun error of the status.
+	StatusCode int
+	// Truncate sends only the first half of the body, which leaves JSON incomplete.
+	Truncate bool
+	// Reset closes the connection without responding.
+	Reset bool
+	// Count limits the fault to the next Count requests. Zero applies it to every request.
+	Count int
+}
+
+// Handler answers Mirakurun API requests from a fixture directory.
+type Handler struct {
+	fsys fs.FS
+
+	mu        sync.Mutex
+	overrides map[string][]byte
+	faults    map[string]*Fault
+	requests  map[string]int
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewHandler returns a Handler serving the fixtures in fsys, e.g. os.DirFS("test/mirakurun").
+func NewHandler(fsys fs.FS) *Handler {
+	return &Handler{
+		fsys:      fsys,
+		overrides: make(map[string][]byte),
+		faults:    make(map[string]*Fault),
+		requests:  make(map[string]int),
+		done:      make(chan struct{}),
+	}
+}
+
+// Set replaces the response of path, e.g. "/api/tuners", with body until Restore is called.
+// A nil body makes path answer 404.
+func (h *Handler) Set(path string, body []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.overrides[path] = body
+}
+
+// SetJSON replaces the response of path with v encoded as JSON.
+func (h *Handler) SetJSON(path string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	h.Set(path, body)
+	return nil
+}
+
+// Restore serves the fixture of path again.
+func (h *Handler) Restore(path string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.overrides, path)
+}
+
+// SetFault injects fault into the responses of path, replacing its previous fault.
+// Use AllEndpoints as path to make every endpoint fail.
+func (h *Handler) SetFault(path string, fault Fault) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.faults[path] = &fault
+}
+
+// ClearFaults removes all faults.
+func (h *Handler) ClearFaults() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	clear(h.faults)
+}
+
+// Requests returns the number of requests to path, including failed ones.
+func (h *Handler) Requests(path string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.requests[path]
+}
+
+// Close ends the open streams.
+func (h *Handler) Close() {
+	h.closeOnce.Do(func() { close(h.done) })
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, "/api/") {
+		writeError(w, http.StatusNotFound)
+		return
+	}
+	fault := h.request(r.URL.Path)
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	if fault.Latency > 0 {
+		timer := time.NewTimer(fault.Latency)
+		select {
+		case <-r.Context().Done():
+			timer.Stop()
+			return
+		case <-h.done:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+	if fault.Reset {
+		resetConnection(w)
+		return
+	}
+	if fault.StatusCode != 0 {
+		writeError(w, fault.StatusCode)
+		return
+	}
+
+	path, isStream := strings.CutSuffix(r.URL.Path, "/stream")
+	body, contentType, err := h.fixture(path)
+	if err != nil {
+		writeError(w, http.StatusNotFound)
+		return
+	}
+	if fault.Truncate {
+		body = body[:len(body)/2]
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if !isStream {
+		_, _ = w.Write(body)
+		return
+	}
+
+	// A stream is an array that is never closed.
+	if contentType == "application/json" {
+		body = []byte(strings.TrimSuffix(strings.TrimSpace(string(body)), "]"))
+	}
+	_, _ = w.Write(body)
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	select {
+	case <-r.Context().Done():
+	case <-h.done:
+	}
+}
+
+// request counts a request to path and returns the fault to inject into it.
+func (h *Handler) request(path string) Fault {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.requests[path]++
+
+	key := path
+	fault, ok := h.faults[key]
+	if !ok {
+		key = AllEndpoints
+		fault, ok = h.faults[key]
+	}
+	if !ok {
+		return Fault{}
+	}
+	if fault.Count > 0 {
+		fault.Count--
+		if fault.Count == 0 {
+			delete(h.faults, key)
+		}
+	}
+	return *fault
+}
+
+// fixture returns the response of path and its content type.
+func (h *Handler) fixture(path string) ([]byte, string, error) {
+	contentType := "application/json"
+	if path == "/api/log" {
+		contentType = "text/plain"
+	}
+
+	h.mu.Lock()
+	body, ok := h.overrides[path]
+	h.mu.Unlock()
+	if ok {
+		if body == nil {
+			return nil, "", fs.ErrNotExist
+		}
+		return body, contentType, nil
+	}
+
+	body, err := fs.ReadFile(h.fsys, FixtureName(path))
+	return body, contentType, err
+}
+
+// FixtureName returns the name of the fixture file holding the response of path, e.g.
+// "channels_GR.json" for "/api/channels/GR".
+func FixtureName(path string) string {
+	name := strings.ReplaceAll(strings.TrimPrefix(path, "/api/"), "/", "_")
+	if name == "log" {
+		return "log.txt"
+	}
+	return name + ".json"
+}
+
+func writeError(w http.ResponseWriter, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_, _ = fmt.Fprintf(w, `{"code":%d,"reason":%q}`, statusCode, http.StatusText(statusCode))
+}
+
+// resetConnection closes the connection of w, with a TCP RST where possible.
+func resetConnection(w http.ResponseWriter) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		_ = tcpConn.SetLinger(0)
+	}
+	_ = conn.Close()
+}
@@ -0,0 +1,331 @@
+package fixture
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Fetcher fetches the responses recorded by Record. *mirakurun.Client implements it.
+type Fetcher interface {
+	GetRaw(ctx context.Context, path string, logger *slog.Logger) ([]byte, error)
+}
+
+// RecordOptions controls what Record removes from the responses before writing them.
+type RecordOptions struct {
+	// RedactClientIPs replaces the IP addresses of clients in tuner user IDs and in the log with
+	// documentation addresses. The same address is always replaced with the same one.
+	RedactClientIPs bool
+	// RedactProcessEnv replaces the values of process.env in /api/status.
+	RedactProcessEnv bool
+}
+
+// redactedValue replaces redacted strings.
+const redactedValue = "REDACTED"
+
+// Record fetches every endpoint the mirakurun package supports and writes the responses to dir
+// in the layout served by Handler. Endpoints with a parameter are recorded for every tuner,
+// channel and service, and for the first program only.
+//
+// Failing to fetch /api/status or /api/version is an error. Other endpoints, e.g. /api/jobs of
+// Mirakurun 3.x or the process of an idle tuner, are skipped with a warning.
+func Record(ctx context.Context, fetcher Fetcher, dir string, opts RecordOptions, logger *slog.Logger) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	r := &recorder{
+		fetcher:  fetcher,
+		dir:      dir,
+		opts:     opts,
+		logger:   logger,
+		redactor: &ipRedactor{mapped: make(map[string]string)},
+	}
+
+	for _, path := range []string{"/api/status", "/api/version"} {
+		if _, err := r.record(ctx, path); err != nil {
+			return err
+		}
+	}
+
+	if body, ok := r.recordOptional(ctx, "/api/tuners"); ok {
+		var tuners []struct {
+			Index int `json:"index"`
+		}
+		r.parse("/api/tuners", body, &tuners)
+		for _, tuner := range tuners {
+			path := "/api/tuners/" + strconv.Itoa(tuner.Index)
+			r.recordOptional(ctx, path)
+			r.recordOptional(ctx, path+"/process")
+		}
+	}
+
+	if body, ok := r.recordOptional(ctx, "/api/channels"); ok {
+		var channels []struct {
+			Type    string `json:"type"`
+			Channel string `json:"channel"`
+		}
+		r.parse("/api/channels", body, &channels)
+		types := make(map[string]bool)
+		for _, channel := range channels {
+			if !types[channel.Type] {
+				types[channel.Type] = true
+				r.recordOptional(ctx, "/api/channels/"+channel.Type)
+			}
+			path := "/api/channels/" + channel.Type + "/" + channel.Channel
+			r.recordOptional(ctx, path)
+			r.recordOptional(ctx, path+"/services")
+		}
+	}
+
+	if body, ok := r.recordOptional(ctx, "/api/services"); ok {
+		var services []struct {
+			ID json.Number `json:"id"`
+		}
+		r.parse("/api/services", body, &services)
+		for _, service := range services {
+			r.recordOptional(ctx, "/api/services/"+service.ID.String())
+		}
+	}
+
+	if body, ok := r.recordOptional(ctx, "/api/programs"); ok {
+		var programs []struct {
+			ID json.Number `json:"id"`
+		}
+		r.parse("/api/programs", body, &programs)
+		if len(programs) > 0 {
+			r.recordOptional(ctx, "/api/programs/"+programs[0].ID.String())
+		}
+	}
+
+	for _, path := range []string{
+		"/api/events",
+		"/api/log",
+		"/api/jobs",
+		"/api/config/server",
+		"/api/config/tuners",
+		"/api/config/channels",
+	} {
+		r.recordOptional(ctx, path)
+	}
+
+	return ctx.Err()
+}
+
+type recorder struct {
+	fetcher  Fetcher
+	dir      string
+	opts     RecordOptions
+	logger   *slog.Logger
+	redactor *ipRedactor
+}
+
+// record fetches path, redacts the response and writes it to its fixture file.
+func (r *recorder) record(ctx context.Context, path string) ([]byte, error) {
+	body, err := r.fetcher.GetRaw(ctx, escapePath(path), r.logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record %s: %w", path, err)
+	}
+
+	written, err := r.redact(path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to redact %s: %w", path, err)
+	}
+	if err := os.WriteFile(filepath.Join(r.dir, FixtureName(path)), written, 0o644); err != nil {
+		return nil, err
+	}
+
+	r.logger.Info("recorded", "path", path, "bytes", len(written))
+	return body, nil
+}
+
+// recordOptional records path and logs a failure instead of returning it.
+func (r *recorder) recordOptional(ctx context.Context, path string) ([]byte, bool) {
+	if ctx.Err() != nil {
+		return nil, false
+	}
+	body, err := r.record(ctx, path)
+	if err != nil {
+		r.logger.Warn("skipped", "path", path, "err", err)
+		return nil, false
+	}
+	return body, true
+}
+
+// parse decodes body to find the endpoints with parameters. A body that cannot be decoded is logged
+// and leaves v empty, skipping those endpoints.
+func (r *recorder) parse(path string, body []byte, v any) {
+	if err := json.Unmarshal(body, v); err != nil {
+		r.logger.Warn("cannot find endpoints from response", "path", path, "err", err)
+	}
+}
+
+func (r *recorder) redact(path string, body []byte) ([]byte, error) {
+	if path == "/api/log" {
+		if r.opts.RedactClientIPs {
+			return []byte(r.redactor.redactText(string(body))), nil
+		}
+		return body, nil
+	}
+
+	redactEnv := r.opts.RedactProcessEnv && path == "/api/status"
+	if !redactEnv && !r.opts.RedactClientIPs {
+		return body, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	changed := false
+	if redactEnv {
+		changed = redactProcessEnv(value)
+	}
+	if r.opts.RedactClientIPs && r.redactor.redactTunerUsers(value) {
+		changed = true
+	}
+	if !changed {
+		return body, nil
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// redactProcessEnv replaces the values of process.env in a status response.
+func redactProcessEnv(status any) bool {
+	object, _ := status.(map[string]any)
+	process, _ := object["process"].(map[string]any)
+	env, _ := process["env"].(map[string]any)
+	for key := range env {
+		env[key] = redactedValue
+	}
+	return len(env) > 0
+}
+
+// ipRedactor replaces IP addresses with documentation addresses, 192.0.2.0/24 for IPv4 and
+// 2001:db8::/32 for IPv6.
+type ipRedactor struct {
+	mapped   map[string]string
+	nextIPv4 netip.Addr
+	nextIPv6 netip.Addr
+}
+
+var ipv4Pattern = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+
+// redactTunerUsers redacts the IDs of tuner users, "<client IP>:<port>", anywhere in value.
+func (r *ipRedactor) redactTunerUsers(value any) bool {
+	changed := false
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if key == "users" {
+				users, _ := item.([]any)
+				for _, user := range users {
+					user, _ := user.(map[string]any)
+					if id, ok := user["id"].(string); ok {
+						user["id"] = r.redactUserID(id)
+						changed = changed || user["id"] != id
+					}
+				}
+			}
+			if r.redactTunerUsers(item) {
+				changed = true
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if r.redactTunerUsers(item) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+func (r *ipRedactor) redactUserID(id string) string {
+	i := strings.LastIndex(id, ":")
+	if i < 0 {
+		return r.redactText(id)
+	}
+	if ip := net.ParseIP(id[:i]); ip != nil {
+		return r.replace(id[:i]) + id[i:]
+	}
+	return r.redactText(id)
+}
+
+// redactText replaces the addresses already seen in user IDs and any IPv4 address in text.
+func (r *ipRedactor) redactText(text string) string {
+	var ipv6 []string
+	for ip := range r.mapped {
+		if strings.Contains(ip, ":") {
+			ipv6 = append(ipv6, ip)
+		}
+	}
+	// Longer addresses first, so that "::1" does not replace a part of "::12".
+	sort.Slice(ipv6, func(i, j int) bool { return len(ipv6[i]) > len(ipv6[j]) })
+	for _, ip := range ipv6 {
+		text = strings.ReplaceAll(text, ip, r.mapped[ip])
+	}
+	return ipv4Pattern.ReplaceAllStringFunc(text, func(ip string) string {
+		if net.ParseIP(ip) == nil {
+			return ip
+		}
+		return r.replace(ip)
+	})
+}
+
+func (r *ipRedactor) replace(ip string) string {
+	if replacement, ok := r.mapped[ip]; ok {
+		return replacement
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+
+	var replacement netip.Addr
+	if addr.Unmap().Is4() && !strings.Contains(ip, ":") {
+		if !r.nextIPv4.IsValid() {
+			r.nextIPv4 = netip.MustParseAddr("192.0.2.0")
+		}
+		r.nextIPv4 = r.nextIPv4.Next()
+		replacement = r.nextIPv4
+	} else {
+		if !r.nextIPv6.IsValid() {
+			r.nextIPv6 = netip.MustParseAddr("2001:db8::")
+		}
+		r.nextIPv6 = r.nextIPv6.Next()
+		replacement = r.nextIPv6
+	}
+	r.mapped[ip] = replacement.String()
+	return r.mapped[ip]
+}
+
+// escapePath escapes the segments of path, e.g. a channel name with a space.
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,130 @@
+package fixture_test
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nasshu2916/mirakurun_exporter/mirakurun"
+	"github.com/nasshu2916/mirakurun_exporter/mirakurun/fixture"
+	"github.com/nasshu2916/mirakurun_exporter/mirakuruntest"
+)
+
+func newClient(t *testing.T, srv *mirakuruntest.Server) *mirakurun.Client {
+	t.Helper()
+	client, err := mirakurun.NewClientWithOptions(srv.URL, mirakurun.WithTimeout(time.Second))
+	require.NoError(t, err)
+	return client
+}
+
+func readFixture(t *testing.T, dir string, name string) string {
+	t.Helper()
+	buf, err := os.ReadFile(filepath.Join(dir, name))
+	require.NoError(t, err)
+	return string(buf)
+}
+
+func TestRecord(t *testing.T) {
+	srv := mirakuruntest.NewServer(t, os.DirFS("../../test/mirakurun"))
+	client := newClient(t, srv)
+	dir := t.TempDir()
+
+	err := fixture.Record(context.Background(), client, dir, fixture.RecordOptions{}, slog.Default())
+	require.NoError(t, err)
+
+	for _, name := range []string{
+		"status.json",
+		"version.json",
+		"tuners.json",
+		"tuners_1.json",
+		"tuners_1_process.json",
+		"channels.json",
+		"channels_GR.json",
+		"channels_GR_T27.json",
+		"channels_GR_T27_services.json",
+		"services.json",
+		"services_3273601024.json",
+		"programs.json",
+		"programs_327360102403001.json",
+		"events.json",
+		"log.txt",
+		"jobs.json",
+		"config_server.json",
+		"config_tuners.json",
+		"config_channels.json",
+	} {
+		assert.Equal(t, readFixture(t, "../../test/mirakurun", name), readFixture(t, dir, name), name)
+	}
+
+	// The recorded directory replays like the original.
+	replay := mirakuruntest.NewServer(t, os.DirFS(dir))
+	tuners, err := newClient(t, replay).GetTuners(context.Background(), slog.Default())
+	require.NoError(t, err)
+	assert.NotEmpty(t, *tuners)
+}
+
+func TestRecord_Redact(t *testing.T) {
+	srv := mirakuruntest.NewServer(t, os.DirFS("../../test/mirakurun"))
+	client := newClient(t, srv)
+	dir := t.TempDir()
+
+	opts := fixture.RecordOptions{RedactClientIPs: true, RedactProcessEnv: true}
+	err := fixture.Record(context.Background(), client, dir, opts, slog.Default())
+	require.NoError(t, err)
+
+	replay := newClient(t, mirakuruntest.NewServer(t, os.DirFS(dir)))
+	ctx := context.Background()
+	logger := slog.Default()
+
+	status, err := replay.GetStatus(ctx, logger)
+	require.NoError(t, err)
+	require.NotEmpty(t, status.Process.Env)
+	for _, value := range status.Process.Env {
+		assert.Equal(t, "REDACTED", value)
+	}
+
+	tuners, err := replay.GetTuners(ctx, logger)
+	require.NoError(t, err)
+	tuner, err := replay.GetTuner(ctx, 1, logger)
+	require.NoError(t, err)
+	var userIDs []string
+	for _, tuner := range *tuners {
+		for _, user := range tuner.Users {
+			userIDs = append(userIDs, user.ID)
+		}
+	}
+	assert.Equal(t, []string{"192.0.2.1:53833"}, userIDs)
+	assert.Equal(t, "192.0.2.1:53833", tuner.Users[0].ID)
+
+	for _, name := range []string{"tuners.json", "tuners_1.json", "events.json", "log.txt"} {
+		assert.NotContains(t, readFixture(t, dir, name), "192.168.1.10", name)
+	}
+	assert.True(t, strings.HasPrefix(readFixture(t, dir, "log.txt"), "2025-06-04T01:20:00.000Z info: 192.0.2.1:53833 -- GET"))
+}
+
+func TestRecord_Error(t *testing.T) {
+	srv := mirakuruntest.NewServer(t, os.DirFS("../../test/mirakurun"))
+	client := newClient(t, srv)
+	dir := t.TempDir()
+
+	// Optional endpoints are skipped.
+	srv.SetFault("/api/jobs", fixture.Fault{StatusCode: http.StatusNotFound})
+	err := fixture.Record(context.Background(), client, dir, fixture.RecordOptions{}, slog.Default())
+	require.NoError(t, err)
+	assert.NoFileExists(t, filepath.Join(dir, "jobs.json"))
+	assert.FileExists(t, filepath.Join(dir, "config_server.json"))
+
+	srv.SetFault("/api/status", fixture.Fault{StatusCode: http.StatusInternalServerError})
+	err = fixture.Record(context.Background(), client, t.TempDir(), fixture.RecordOptions{}, slog.Default())
+	var statusErr *mirakurun.StatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusInternalServerError, statusErr.StatusCode)
+}
@@ -2,9 +2,7 @@ package mirakurun
 
 import (
 	"context"
-	"fmt"
 	"log/slog"
-	"net/http"
 )
 
 type JobsResponse []struct {
@@ -31,19 +29,5 @@ type JobsResponse []struct {
 }
 
 func (c *Client) GetJobs(ctx context.Context, logger *slog.Logger) (*JobsResponse, error) {
-	resp, err := c.request(ctx, "GET", "/api/jobs", nil, logger)
-	if err != nil {
-		return nil, err
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("request failed with status code: %d", resp.StatusCode)
-	}
-
-	var programs JobsResponse
-	if err := decodeBody(resp, &programs); err != nil {
-		return nil, err
-	}
-
-	return &programs, nil
+	return get[JobsResponse](ctx, c, "/api/jobs", logger)
 }
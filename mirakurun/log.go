@@ -0,0 +1,92 @@
+package mirakurun
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type LogResponse []LogLine
+
+// LogLine is a line of the Mirakurun log, formatted as "<ISO 8601 time> <level>: <message>".
+// Lines that do not follow the format, such as continuation lines of a stack trace, only have Raw.
+type LogLine struct {
+	Raw     string
+	Time    time.Time
+	Level   string
+	Message string
+}
+
+// ParseLogLine parses a line of the Mirakurun log.
+func ParseLogLine(line string) LogLine {
+	logLine := LogLine{Raw: line}
+
+	timestamp, rest, ok := strings.Cut(line, " ")
+	if !ok {
+		return logLine
+	}
+	t, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return logLine
+	}
+	level, message, ok := strings.Cut(rest, ": ")
+	if !ok || strings.Contains(level, " ") {
+		return logLine
+	}
+
+	logLine.Time = t
+	logLine.Level = level
+	logLine.Message = message
+	return logLine
+}
+
+// GetLog returns the log lines kept by Mirakurun.
+func (c *Client) GetLog(ctx context.Context, logger *slog.Logger) (*LogResponse, error) {
+	return cached(ctx, c, "/api/log", logger, func(ctx context.Context) (*LogResponse, error) {
+		resp, err := c.request(ctx, http.MethodGet, "/api/log", nil, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		var lines LogResponse
+		err = c.read(resp, func(body io.Reader) error {
+			return scanLogLines(body, func(line LogLine) {
+				lines = append(lines, line)
+			})
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return &lines, nil
+	})
+}
+
+// SubscribeLog follows /api/log/stream and calls fn for each new log line until ctx is done.
+// The stream is reconnected with backoff when it fails or Mirakurun closes it, e.g. on restart.
+// It returns the error of ctx.
+func (c *Client) SubscribeLog(ctx context.Context, fn func(line LogLine), logger *slog.Logger) error {
+	return c.stream(ctx, "/api/log/stream", logger, func(body io.Reader) error {
+		return scanLogLines(body, fn)
+	})
+}
+
+// maxLogLineSize is the longest log line read. Longer lines, such as huge stack traces, fail the read.
+const maxLogLineSize = 1024 * 1024
+
+// scanLogLines parses each non-empty line of r.
+func scanLogLines(r io.Reader, fn func(line LogLine)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLogLineSize)
+	for scanner.Scan() {
+		if scanner.Text() == "" {
+			continue
+		}
+		fn(ParseLogLine(scanner.Text()))
+	}
+	return scanner.Err()
+}
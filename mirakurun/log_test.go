@@ -0,0 +1,133 @@
+package mirakurun
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLogLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want LogLine
+	}{
+		{
+			name: "正常系",
+			line: "2025-06-04T01:25:30.004Z warning: TunerDevice#1 process has exited with exit code=1",
+			want: LogLine{
+				Raw:     "2025-06-04T01:25:30.004Z warning: TunerDevice#1 process has exited with exit code=1",
+				Time:    time.Date(2025, 6, 4, 1, 25, 30, 4000000, time.UTC),
+				Level:   "warning",
+				Message: "TunerDevice#1 process has exited with exit code=1",
+			},
+		},
+		{
+			name: "正常系: メッセージ中のコロン",
+			line: "2025-06-04T01:25:30.010Z error: EPG gathering has failed [Error: stream has closed]",
+			want: LogLine{
+				Raw:     "2025-06-04T01:25:30.010Z error: EPG gathering has failed [Error: stream has closed]",
+				Time:    time.Date(2025, 6, 4, 1, 25, 30, 10000000, time.UTC),
+				Level:   "error",
+				Message: "EPG gathering has failed [Error: stream has closed]",
+			},
+		},
+		{
+			name: "エラー系: 継続行",
+			line: "    at TSFilter._close (/app/lib/Mirakurun/TSFilter.js:715:19)",
+			want: LogLine{Raw: "    at TSFilter._close (/app/lib/Mirakurun/TSFilter.js:715:19)"},
+		},
+		{
+			name: "エラー系: レベルなし",
+			line: "2025-06-04T01:25:30.010Z started",
+			want: LogLine{Raw: "2025-06-04T01:25:30.010Z started"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, ParseLogLine(tt.line)); diff != "" {
+				t.Fatalf("log line mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGetLog(t *testing.T) {
+	c := newFixtureClient(t, "/api/log", "log.txt")
+
+	lines, err := c.GetLog(context.Background(), slog.Default())
+	require.NoError(t, err)
+
+	var levels []string
+	for _, line := range *lines {
+		levels = append(levels, line.Level)
+	}
+
+	want := []string{"info", "debug", "warning", "error", "", "info"}
+	if diff := cmp.Diff(want, levels); diff != "" {
+		t.Fatalf("log levels mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSubscribeLog(t *testing.T) {
+	var connections atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/log/stream" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch connections.Add(1) {
+		case 1:
+			// Mirakurun closes the stream when it restarts.
+			_, _ = w.Write([]byte("2025-06-04T01:25:30.004Z warning: TunerDevice#1 process has exited with exit code=1\n"))
+		default:
+			_, _ = w.Write([]byte("2025-06-04T01:26:00.000Z info: TunerDevice#1 released\n\n"))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewClientWithOptions(srv.URL, WithStreamBackoff(10*time.Millisecond, 20*time.Millisecond))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	received := make(chan LogLine, 10)
+	done := make(chan error)
+	go func() {
+		done <- c.SubscribeLog(ctx, func(line LogLine) {
+			received <- line
+		}, slog.Default())
+	}()
+
+	var levels []string
+	for len(levels) < 2 {
+		select {
+		case line := <-received:
+			levels = append(levels, line.Level)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for log lines, got %v", levels)
+		}
+	}
+	if diff := cmp.Diff([]string{"warning", "info"}, levels); diff != "" {
+		t.Fatalf("log levels mismatch (-want +got):\n%s", diff)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("SubscribeLog did not return after cancel")
+	}
+}
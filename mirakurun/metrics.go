@@ -0,0 +1,124 @@
+package mirakurun
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "mirakurun_exporter"
+
+// routeParams are the names of the path parameters following each API resource.
+var routeParams = map[string][]string{
+	"tuners":   {"{index}"},
+	"channels": {"{type}", "{channel}"},
+	"services": {"{id}"},
+	"programs": {"{id}"},
+}
+
+// routeOf returns the route of an API path for use as a metric label, so that
+// e.g. /api/services/3273601024 and /api/services/400101 share the label /api/services/{id}.
+func routeOf(path string) string {
+	path, _, _ = strings.Cut(path, "?")
+	resource, rest, found := strings.Cut(strings.TrimPrefix(path, "/api/"), "/")
+	params := routeParams[resource]
+	if !found || len(params) == 0 {
+		return path
+	}
+
+	segments := strings.Split(rest, "/")
+	for i := 0; i < len(segments) && i < len(params); i++ {
+		segments[i] = params[i]
+	}
+	return "/api/" + resource + "/" + strings.Join(segments, "/")
+}
+
+type clientMetrics struct {
+	requests         *prometheus.CounterVec
+	requestDuration  *prometheus.HistogramVec
+	responseSize     *prometheus.HistogramVec
+	decodeDuration   *prometheus.HistogramVec
+	retries          *prometheus.CounterVec
+	retriesExhausted *prometheus.CounterVec
+	streamReconnects *prometheus.CounterVec
+	streamConnected  *prometheus.GaugeVec
+}
+
+func newClientMetrics() *clientMetrics {
+	return &clientMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "requests_total",
+			Help:      "Number of Mirakurun API requests by status code, code is \"error\" when no response was received",
+		}, []string{"path", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "request_duration_seconds",
+			Help:      "Duration of Mirakurun API requests until the response headers are received",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"path"}),
+		responseSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "response_size_bytes",
+			Help:      "Size of Mirakurun API response bodies",
+			Buckets:   prometheus.ExponentialBuckets(256, 4, 10),
+		}, []string{"path"}),
+		decodeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "response_decode_duration_seconds",
+			Help:      "Duration of reading and decoding Mirakurun API response bodies",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"path"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "request_retries_total",
+			Help:      "Number of retried Mirakurun API requests",
+		}, []string{"path"}),
+		retriesExhausted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "request_retries_exhausted_total",
+			Help:      "Number of Mirakurun API requests that still failed after retrying",
+		}, []string{"path"}),
+		streamReconnects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "stream_reconnects_total",
+			Help:      "Number of reconnections to Mirakurun streams",
+		}, []string{"path"}),
+		streamConnected: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "stream_connected",
+			Help:      "Whether a Mirakurun stream is connected",
+		}, []string{"path"}),
+	}
+}
+
+func (m *clientMetrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.requests,
+		m.requestDuration,
+		m.responseSize,
+		m.decodeDuration,
+		m.retries,
+		m.retriesExhausted,
+		m.streamReconnects,
+		m.streamConnected,
+	}
+}
+
+// Describe implements prometheus.Collector. The Client exposes metrics about its own API requests.
+func (c *Client) Describe(ch chan<- *prometheus.Desc) {
+	for _, collector := range c.metrics.collectors() {
+		collector.Describe(ch)
+	}
+	c.cache.Describe(ch)
+	c.schemaReport.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Client) Collect(ch chan<- prometheus.Metric) {
+	for _, collector := range c.metrics.collectors() {
+		collector.Collect(ch)
+	}
+	c.cache.Collect(ch)
+	c.schemaReport.Collect(ch)
+}
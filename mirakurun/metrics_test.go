@@ -0,0 +1,111 @@
+package mirakurun
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nasshu2916/mirakurun_exporter/util"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func histogramOf(t *testing.T, observer prometheus.Observer) *dto.Histogram {
+	t.Helper()
+	metric := &dto.Metric{}
+	require.NoError(t, observer.(prometheus.Metric).Write(metric))
+	return metric.GetHistogram()
+}
+
+func TestClient_Metrics(t *testing.T) {
+	testHelper := &util.TestHelper{}
+
+	responseBody := testHelper.ReadFile(t, "../test/mirakurun/status.json")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/status" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(responseBody))
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, 1)
+	require.NoError(t, err)
+
+	_, err = c.GetStatus(context.Background(), slog.Default())
+	require.NoError(t, err)
+	_, err = c.GetTuners(context.Background(), slog.Default())
+	require.Error(t, err)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.metrics.requests.WithLabelValues("/api/status", "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.metrics.requests.WithLabelValues("/api/tuners", "404")))
+
+	assert.Equal(t, uint64(1), histogramOf(t, c.metrics.requestDuration.WithLabelValues("/api/status")).GetSampleCount())
+	assert.Equal(t, uint64(1), histogramOf(t, c.metrics.decodeDuration.WithLabelValues("/api/status")).GetSampleCount())
+
+	size := histogramOf(t, c.metrics.responseSize.WithLabelValues("/api/status"))
+	assert.Equal(t, uint64(1), size.GetSampleCount())
+	assert.Equal(t, float64(len(responseBody)), size.GetSampleSum())
+
+	// A failed request has no body to decode.
+	assert.Equal(t, uint64(0), histogramOf(t, c.metrics.decodeDuration.WithLabelValues("/api/tuners")).GetSampleCount())
+}
+
+func TestClient_MetricsTransportError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	c, err := NewClient("http://"+addr, 1)
+	require.NoError(t, err)
+
+	_, err = c.GetStatus(context.Background(), slog.Default())
+	require.Error(t, err)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.metrics.requests.WithLabelValues("/api/status", "error")))
+}
+
+func TestClient_Describe(t *testing.T) {
+	c, err := NewClient("http://localhost:40772", 1)
+	require.NoError(t, err)
+
+	registry := prometheus.NewPedanticRegistry()
+	require.NoError(t, registry.Register(c))
+
+	problems, err := testutil.CollectAndLint(c)
+	require.NoError(t, err)
+	assert.Empty(t, problems)
+}
+
+func TestRouteOf(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/api/status", want: "/api/status"},
+		{path: "/api/tuners", want: "/api/tuners"},
+		{path: "/api/tuners/1", want: "/api/tuners/{index}"},
+		{path: "/api/tuners/1/process", want: "/api/tuners/{index}/process"},
+		{path: "/api/channels/GR", want: "/api/channels/{type}"},
+		{path: "/api/channels/GR/T27/services", want: "/api/channels/{type}/{channel}/services"},
+		{path: "/api/services/3273601024", want: "/api/services/{id}"},
+		{path: "/api/programs?networkId=32736&serviceId=1024", want: "/api/programs"},
+		{path: "/api/config/server", want: "/api/config/server"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.want, routeOf(tt.path))
+		})
+	}
+}
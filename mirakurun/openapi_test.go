@@ -0,0 +1,359 @@
+package mirakurun
+
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/nasshu2916/mirakurun_exporter/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openAPISchema is the subset of a Swagger 2.0 schema object the contract tests look at.
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref"`
+	Type                 string                    `json:"type"`
+	Properties           map[string]*openAPISchema `json:"properties"`
+	Required             []string                  `json:"required"`
+	Items                *openAPISchema            `json:"items"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties"`
+	Nullable             bool                      `json:"x-nullable"`
+}
+
+type openAPIParameter struct {
+	Name string `json:"name"`
+	In   string `json:"in"`
+	Type string `json:"type"`
+}
+
+type openAPIOperation struct {
+	Produces   []string           `json:"produces"`
+	Parameters []openAPIParameter `json:"parameters"`
+	Responses  map[string]struct {
+		Schema *openAPISchema `json:"schema"`
+	} `json:"responses"`
+}
+
+type openAPIDocument struct {
+	BasePath    string                                 `json:"basePath"`
+	Paths       map[string]map[string]openAPIOperation `json:"paths"`
+	Definitions map[string]*openAPISchema              `json:"definitions"`
+}
+
+// openAPIDeviations are fields where the structs knowingly differ from the document, by struct and JSON name.
+var openAPIDeviations = map[string]string{
+	// null is returned while the tuner is not running and decoded as the zero value.
+	"Tuner.command": "nullable",
+	"Tuner.pid":     "nullable",
+	// Kept from the first version of the struct; Mirakurun only returns type and channel.
+	"ServiceChannel.name":      "undocumented",
+	"ServiceChannel.tsmfRelTs": "undocumented",
+	"ServiceChannel.services":  "undocumented",
+}
+
+func readOpenAPIDocument(t *testing.T) *openAPIDocument {
+	t.Helper()
+	testHelper := &util.TestHelper{}
+
+	var doc openAPIDocument
+	require.NoError(t, json.Unmarshal([]byte(testHelper.ReadFile(t, "../test/mirakurun/openapi.json")), &doc))
+	require.Equal(t, "/api", doc.BasePath)
+	return &doc
+}
+
+// resolve follows $ref to the definition it refers to.
+func (d *openAPIDocument) resolve(t *testing.T, schema *openAPISchema) *openAPISchema {
+	t.Helper()
+	for schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/definitions/")
+		definition, ok := d.Definitions[name]
+		require.True(t, ok, "undefined %s", schema.Ref)
+		schema = definition
+	}
+	return schema
+}
+
+// checkType reports every difference between the Go type typ and schema to t, naming the location path.
+func (d *openAPIDocument) checkType(t *testing.T, typ reflect.Type, schema *openAPISchema, path string) {
+	t.Helper()
+	schema = d.resolve(t, schema)
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ == rawMessageType || typ.Kind() == reflect.Interface {
+		return
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		assert.Equal(t, "boolean", schema.Type, "%s: %s", path, typ)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		assert.Equal(t, "integer", schema.Type, "%s: %s", path, typ)
+	case reflect.Float32, reflect.Float64:
+		assert.Contains(t, []string{"number", "integer"}, schema.Type, "%s: %s", path, typ)
+	case reflect.String:
+		assert.Equal(t, "string", schema.Type, "%s: %s", path, typ)
+	case reflect.Slice, reflect.Array:
+		if assert.Equal(t, "array", schema.Type, "%s: %s", path, typ) && assert.NotNil(t, schema.Items, path) {
+			d.checkType(t, typ.Elem(), schema.Items, path+"[]")
+		}
+	case reflect.Map:
+		if !assert.Equal(t, "object", schema.Type, "%s: %s", path, typ) {
+			return
+		}
+		assert.Empty(t, schema.Properties, "%s: %s has fixed properties", path, typ)
+		if schema.AdditionalProperties != nil {
+			d.checkType(t, typ.Elem(), schema.AdditionalProperties, path+".*")
+		}
+	case reflect.Struct:
+		if !assert.Equal(t, "object", schema.Type, "%s: %s", path, typ) {
+			return
+		}
+		d.checkStruct(t, typ, schema, path)
+	default:
+		t.Errorf("%s: unsupported type %s", path, typ)
+	}
+}
+
+func (d *openAPIDocument) checkStruct(t *testing.T, typ reflect.Type, schema *openAPISchema, path string) {
+	t.Helper()
+	fields := jsonFields(typ)
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fieldType := fields[name]
+		fieldPath := joinField(path, name)
+		deviation := openAPIDeviations[typ.Name()+"."+name]
+
+		property, ok := matchProperty(schema.Properties, name)
+		if !ok {
+			assert.Equal(t, "undocumented", deviation, "%s: %s is not in the document", fieldPath, typ)
+			continue
+		}
+		assert.NotEqual(t, "undocumented", deviation, "%s: deviation is outdated", fieldPath)
+
+		if property.Nullable {
+			switch fieldType.Kind() {
+			case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface:
+			default:
+				assert.Equal(t, "nullable", deviation, "%s: nullable but %s cannot hold null", fieldPath, fieldType)
+			}
+		} else {
+			assert.NotEqual(t, "nullable", deviation, "%s: deviation is outdated", fieldPath)
+		}
+		d.checkType(t, fieldType, property, fieldPath)
+	}
+
+	for _, required := range schema.Required {
+		_, ok := matchField(fields, required)
+		assert.True(t, ok, "%s: required property %q is not in %s", path, required, typ)
+	}
+}
+
+// matchProperty looks a struct field up in properties the same way encoding/json matches keys.
+func matchProperty(properties map[string]*openAPISchema, name string) (*openAPISchema, bool) {
+	if property, ok := properties[name]; ok {
+		return property, true
+	}
+	for key, property := range properties {
+		if strings.EqualFold(key, name) {
+			return property, true
+		}
+	}
+	return nil, false
+}
+
+// openAPIContracts are the response structs of the Client by the path of the operation returning them.
+var openAPIContracts = []struct {
+	path string
+	typ  reflect.Type
+}{
+	{path: "/status", typ: reflect.TypeFor[StatusResponse]()},
+	{path: "/version", typ: reflect.TypeFor[VersionResponse]()},
+	{path: "/tuners", typ: reflect.TypeFor[TunersResponse]()},
+	{path: "/tuners/{index}", typ: reflect.TypeFor[Tuner]()},
+	{path: "/tuners/{index}/process", typ: reflect.TypeFor[TunerProcess]()},
+	{path: "/channels", typ: reflect.TypeFor[ChannelsResponse]()},
+	{path: "/channels/{type}", typ: reflect.TypeFor[ChannelsResponse]()},
+	{path: "/channels/{type}/{channel}", typ: reflect.TypeFor[Channel]()},
+	{path: "/channels/{type}/{channel}/services", typ: reflect.TypeFor[ServicesResponse]()},
+	{path: "/services", typ: reflect.TypeFor[ServicesResponse]()},
+	{path: "/services/{id}", typ: reflect.TypeFor[Service]()},
+	{path: "/programs", typ: reflect.TypeFor[ProgramsResponse]()},
+	{path: "/programs/{id}", typ: reflect.TypeFor[Program]()},
+	{path: "/events", typ: reflect.TypeFor[EventsResponse]()},
+	{path: "/events/stream", typ: reflect.TypeFor[EventsResponse]()},
+	{path: "/jobs", typ: reflect.TypeFor[JobsResponse]()},
+	{path: "/config/server", typ: reflect.TypeFor[ServerConfig]()},
+	{path: "/config/tuners", typ: reflect.TypeFor[TunersConfig]()},
+	{path: "/config/channels", typ: reflect.TypeFor[ChannelsConfig]()},
+}
+
+// openAPIUncheckedResponses are results of Client methods that are not decoded from a JSON schema of the document.
+var openAPIUncheckedResponses = map[reflect.Type]string{
+	// Plain text, see TestOpenAPIContract_Log.
+	reflect.TypeFor[LogResponse](): "text/plain",
+	reflect.TypeFor[LogLine]():     "text/plain",
+	// Counted while decoding /programs, whose schema is checked through ProgramsResponse.
+	reflect.TypeFor[ProgramCounts](): "derived",
+}
+
+func TestOpenAPIContract(t *testing.T) {
+	readOpenAPIDocument(t).checkContracts(t)
+}
+
+// TestOpenAPIContract_UnmappedOperations checks that the operations of Mirakurun's full document that
+// the Client does not use, such as the PUT, DELETE and stream operations, are left out of the contract tests.
+func TestOpenAPIContract_UnmappedOperations(t *testing.T) {
+	doc := readOpenAPIDocument(t)
+	unchecked := openAPIOperation{Responses: map[string]struct {
+		Schema *openAPISchema `json:"schema"`
+	}{"200": {Schema: &openAPISchema{Type: "string"}}}}
+	doc.Paths["/tuners/{index}"]["delete"] = unchecked
+	doc.Paths["/config/server"]["put"] = unchecked
+	doc.Paths["/services/{id}/logo"] = map[string]openAPIOperation{"get": unchecked}
+	doc.Paths["/iptv/playlist"] = map[string]openAPIOperation{"get": unchecked}
+
+	doc.checkContracts(t)
+}
+
+// checkContracts checks the GET operations of openAPIContracts. Other operations of the document are ignored.
+func (d *openAPIDocument) checkContracts(t *testing.T) {
+	t.Helper()
+	for _, tt := range openAPIContracts {
+		t.Run(tt.path, func(t *testing.T) {
+			operation, ok := d.Paths[tt.path]["get"]
+			require.True(t, ok, "GET %s is not in the document", tt.path)
+			response, ok := operation.Responses["200"]
+			require.True(t, ok)
+			require.NotNil(t, response.Schema)
+
+			d.checkType(t, tt.typ, response.Schema, "")
+		})
+	}
+}
+
+// TestOpenAPIContract_Coverage fails when a method of the Client returns or streams a struct that
+// openAPIContracts does not check, so that new endpoints cannot skip the contract tests.
+func TestOpenAPIContract_Coverage(t *testing.T) {
+	checked := make(map[reflect.Type]bool)
+	for _, contract := range openAPIContracts {
+		checked[contract.typ] = true
+	}
+
+	clientType := reflect.TypeFor[*Client]()
+	for i := 0; i < clientType.NumMethod(); i++ {
+		method := clientType.Method(i)
+		for _, typ := range clientResults(method.Type) {
+			if _, ok := openAPIUncheckedResponses[typ]; ok {
+				continue
+			}
+			assert.True(t, checked[typ] || containedIn(typ, checked), "%s returns %s, which has no schema in openAPIContracts", method.Name, typ)
+		}
+	}
+}
+
+// clientResults returns the types of this package a Client method returns or passes to its callback.
+func clientResults(method reflect.Type) []reflect.Type {
+	var types []reflect.Type
+	add := func(typ reflect.Type) {
+		for typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+		if typ.PkgPath() == reflect.TypeFor[Client]().PkgPath() {
+			types = append(types, typ)
+		}
+	}
+	for i := 0; i < method.NumOut(); i++ {
+		add(method.Out(i))
+	}
+	for i := 0; i < method.NumIn(); i++ {
+		if in := method.In(i); in.Kind() == reflect.Func {
+			for j := 0; j < in.NumIn(); j++ {
+				add(in.In(j))
+			}
+		}
+	}
+	return types
+}
+
+// containedIn reports whether typ is an element or field type of one of the checked types,
+// e.g. Event as the element of EventsResponse.
+func containedIn(typ reflect.Type, checked map[reflect.Type]bool) bool {
+	seen := make(map[reflect.Type]bool)
+	var walk func(t reflect.Type) bool
+	walk = func(t reflect.Type) bool {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if t == typ {
+			return true
+		}
+		if seen[t] {
+			return false
+		}
+		seen[t] = true
+		switch t.Kind() {
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return walk(t.Elem())
+		case reflect.Struct:
+			for _, field := range jsonFields(t) {
+				if walk(field) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	for t := range checked {
+		if walk(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestOpenAPIContract_Log(t *testing.T) {
+	doc := readOpenAPIDocument(t)
+
+	// The log is plain text, parsed line by line into LogResponse.
+	for _, path := range []string{"/log", "/log/stream"} {
+		t.Run(path, func(t *testing.T) {
+			operation, ok := doc.Paths[path]["get"]
+			require.True(t, ok, "GET %s is not in the document", path)
+			assert.Equal(t, []string{"text/plain"}, operation.Produces)
+			assert.Equal(t, "string", operation.Responses["200"].Schema.Type)
+		})
+	}
+}
+
+func TestOpenAPIContract_ProgramsQuery(t *testing.T) {
+	doc := readOpenAPIDocument(t)
+
+	operation, ok := doc.Paths["/programs"]["get"]
+	require.True(t, ok)
+	parameters := make(map[string]openAPIParameter)
+	for _, parameter := range operation.Parameters {
+		parameters[parameter.Name] = parameter
+	}
+
+	query, err := url.ParseQuery(ProgramsQuery{NetworkID: 1, ServiceID: 1, EventID: 1}.encode())
+	require.NoError(t, err)
+	for key := range query {
+		parameter, ok := parameters[key]
+		if assert.True(t, ok, "query parameter %q is not in the document", key) {
+			assert.Equal(t, "query", parameter.In)
+			assert.Equal(t, "integer", parameter.Type)
+		}
+	}
+}
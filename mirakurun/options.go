@@ -0,0 +1,200 @@
+package mirakurun
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRequestTimeout = 5 * time.Second
+	defaultUserAgent      = "mirakurun_exporter"
+)
+
+// Option configures a Client created by NewClientWithOptions.
+type Option func(*clientConfig) error
+
+type clientConfig struct {
+	timeout     time.Duration
+	httpClient  *http.Client
+	headers     http.Header
+	userAgent   string
+	basicAuth   *basicAuth
+	bearerToken string
+	tlsConfig   *TLSConfig
+	retry       retryPolicy
+
+	cacheTTLs     map[string]time.Duration
+	cacheMaxStale time.Duration
+
+	maxResponseSize int64
+
+	streamBackoff retryPolicy
+
+	strictSchema bool
+}
+
+type basicAuth struct {
+	username string
+	password string
+}
+
+// TLSConfig describes how to verify Mirakurun (or a reverse proxy in front of it)
+// and which client certificate to present.
+type TLSConfig struct {
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	ServerName         string
+	InsecureSkipVerify bool
+}
+
+// WithTimeout sets the timeout of each HTTP request.
+// It is ignored when WithHTTPClient is given.
+func WithTimeout(timeout time.Duration) Option {
+	return func(cfg *clientConfig) error {
+		cfg.timeout = timeout
+		return nil
+	}
+}
+
+// WithHTTPClient makes the Client send requests with httpClient as is.
+// It cannot be combined with WithTLSConfig or a Unix domain socket URL.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(cfg *clientConfig) error {
+		if httpClient == nil {
+			return fmt.Errorf("http client is nil")
+		}
+		cfg.httpClient = httpClient
+		return nil
+	}
+}
+
+// WithHeader adds a header sent with every request.
+func WithHeader(key, value string) Option {
+	return func(cfg *clientConfig) error {
+		if key == "" {
+			return fmt.Errorf("header name is empty")
+		}
+		cfg.headers.Add(key, value)
+		return nil
+	}
+}
+
+// WithUserAgent overrides the User-Agent header sent with every request.
+func WithUserAgent(userAgent string) Option {
+	return func(cfg *clientConfig) error {
+		cfg.userAgent = userAgent
+		return nil
+	}
+}
+
+// WithBasicAuth sends every request with HTTP basic authentication.
+func WithBasicAuth(username, password string) Option {
+	return func(cfg *clientConfig) error {
+		if username == "" {
+			return fmt.Errorf("basic auth username is empty")
+		}
+		cfg.basicAuth = &basicAuth{username: username, password: password}
+		return nil
+	}
+}
+
+// WithBasicAuthPasswordFile is like WithBasicAuth but reads the password from passwordFile.
+func WithBasicAuthPasswordFile(username, passwordFile string) Option {
+	return func(cfg *clientConfig) error {
+		if username == "" {
+			return fmt.Errorf("basic auth username is empty")
+		}
+		password, err := readSecretFile(passwordFile)
+		if err != nil {
+			return fmt.Errorf("failed to read basic auth password file: %w", err)
+		}
+		cfg.basicAuth = &basicAuth{username: username, password: password}
+		return nil
+	}
+}
+
+// WithBearerToken sends every request with the given bearer token.
+func WithBearerToken(token string) Option {
+	return func(cfg *clientConfig) error {
+		cfg.bearerToken = token
+		return nil
+	}
+}
+
+// WithBearerTokenFile is like WithBearerToken but reads the token from tokenFile.
+func WithBearerTokenFile(tokenFile string) Option {
+	return func(cfg *clientConfig) error {
+		token, err := readSecretFile(tokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read bearer token file: %w", err)
+		}
+		cfg.bearerToken = token
+		return nil
+	}
+}
+
+// WithTLSConfig configures TLS for https:// URLs.
+func WithTLSConfig(tlsConfig TLSConfig) Option {
+	return func(cfg *clientConfig) error {
+		cfg.tlsConfig = &tlsConfig
+		return nil
+	}
+}
+
+// WithMaxResponseSize fails requests whose response body is larger than maxBytes
+// with a ResponseTooLargeError. 0 means no limit.
+func WithMaxResponseSize(maxBytes int64) Option {
+	return func(cfg *clientConfig) error {
+		if maxBytes < 0 {
+			return fmt.Errorf("max response size must not be negative")
+		}
+		cfg.maxResponseSize = maxBytes
+		return nil
+	}
+}
+
+func readSecretFile(path string) (string, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(buf)), nil
+}
+
+func (tlsConfig *TLSConfig) build() (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:         tlsConfig.ServerName,
+		InsecureSkipVerify: tlsConfig.InsecureSkipVerify,
+	}
+
+	if tlsConfig.CAFile != "" {
+		ca, err := os.ReadFile(tlsConfig.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in CA file: %s", tlsConfig.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if (tlsConfig.CertFile == "") != (tlsConfig.KeyFile == "") {
+		return nil, fmt.Errorf("both cert file and key file must be specified")
+	}
+	if tlsConfig.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(tlsConfig.CertFile, tlsConfig.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
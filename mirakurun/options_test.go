@@ -0,0 +1,293 @@
+package mirakurun
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nasshu2916/mirakurun_exporter/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTempFile(t *testing.T, name string, content []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, content, 0o600))
+	return path
+}
+
+func newVersionServer(t *testing.T, tlsServer bool, received chan<- *http.Request) *httptest.Server {
+	t.Helper()
+	testHelper := &util.TestHelper{}
+
+	responseBody := testHelper.ReadFile(t, "../test/mirakurun/version.json")
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if received != nil {
+			received <- r
+		}
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write([]byte(responseBody)); err != nil {
+			t.Errorf("failed to write response: %v", err)
+		}
+	})
+
+	var srv *httptest.Server
+	if tlsServer {
+		srv = httptest.NewTLSServer(handler)
+	} else {
+		srv = httptest.NewServer(handler)
+	}
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestNewClientWithOptions_Headers(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  func(t *testing.T) []Option
+		check func(t *testing.T, r *http.Request)
+	}{
+		{
+			name: "default user agent",
+			opts: func(t *testing.T) []Option { return nil },
+			check: func(t *testing.T, r *http.Request) {
+				assert.Equal(t, "mirakurun_exporter", r.UserAgent())
+				assert.Empty(t, r.Header.Get("Authorization"))
+			},
+		},
+		{
+			name: "extra headers and user agent",
+			opts: func(t *testing.T) []Option {
+				return []Option{
+					WithHeader("X-Forwarded-User", "prometheus"),
+					WithUserAgent("custom-agent/1.0"),
+				}
+			},
+			check: func(t *testing.T, r *http.Request) {
+				assert.Equal(t, "prometheus", r.Header.Get("X-Forwarded-User"))
+				assert.Equal(t, "custom-agent/1.0", r.UserAgent())
+			},
+		},
+		{
+			name: "basic auth password file",
+			opts: func(t *testing.T) []Option {
+				passwordFile := writeTempFile(t, "password", []byte("secret\n"))
+				return []Option{WithBasicAuthPasswordFile("mirakurun", passwordFile)}
+			},
+			check: func(t *testing.T, r *http.Request) {
+				username, password, ok := r.BasicAuth()
+				require.True(t, ok)
+				assert.Equal(t, "mirakurun", username)
+				assert.Equal(t, "secret", password)
+			},
+		},
+		{
+			name: "bearer token file",
+			opts: func(t *testing.T) []Option {
+				tokenFile := writeTempFile(t, "token", []byte("token-value\n"))
+				return []Option{WithBearerTokenFile(tokenFile)}
+			},
+			check: func(t *testing.T, r *http.Request) {
+				assert.Equal(t, "Bearer token-value", r.Header.Get("Authorization"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received := make(chan *http.Request, 1)
+			srv := newVersionServer(t, false, received)
+
+			c, err := NewClientWithOptions(srv.URL, tt.opts(t)...)
+			require.NoError(t, err)
+
+			_, err = c.GetVersion(context.Background(), slog.Default())
+			require.NoError(t, err)
+
+			tt.check(t, <-received)
+		})
+	}
+}
+
+func TestNewClientWithOptions_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		opts []Option
+	}{
+		{
+			name: "basic auth and bearer token",
+			url:  "http://localhost:40772",
+			opts: []Option{WithBasicAuth("user", "pass"), WithBearerToken("token")},
+		},
+		{
+			name: "missing password file",
+			url:  "http://localhost:40772",
+			opts: []Option{WithBasicAuthPasswordFile("user", "/nonexistent/password")},
+		},
+		{
+			name: "password file without username",
+			url:  "http://localhost:40772",
+			opts: []Option{WithBasicAuthPasswordFile("", "/etc/mirakurun_exporter/password")},
+		},
+		{
+			name: "missing token file",
+			url:  "http://localhost:40772",
+			opts: []Option{WithBearerTokenFile("/nonexistent/token")},
+		},
+		{
+			name: "cert without key",
+			url:  "https://localhost:40772",
+			opts: []Option{WithTLSConfig(TLSConfig{CertFile: "client.crt"})},
+		},
+		{
+			name: "custom http client with unix socket",
+			url:  "unix:///var/run/mirakurun.sock",
+			opts: []Option{WithHTTPClient(&http.Client{})},
+		},
+		{
+			name: "custom http client with tls config",
+			url:  "https://localhost:40772",
+			opts: []Option{WithHTTPClient(&http.Client{}), WithTLSConfig(TLSConfig{InsecureSkipVerify: true})},
+		},
+		{
+			name: "nil http client",
+			url:  "http://localhost:40772",
+			opts: []Option{WithHTTPClient(nil)},
+		},
+		{
+			name: "empty header name",
+			url:  "http://localhost:40772",
+			opts: []Option{WithHeader("", "value")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewClientWithOptions(tt.url, tt.opts...)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestNewClientWithOptions_HTTPClient(t *testing.T) {
+	srv := newVersionServer(t, false, nil)
+
+	var called bool
+	httpClient := &http.Client{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			called = true
+			return http.DefaultTransport.RoundTrip(r)
+		}),
+	}
+
+	c, err := NewClientWithOptions(srv.URL, WithHTTPClient(httpClient))
+	require.NoError(t, err)
+
+	_, err = c.GetVersion(context.Background(), slog.Default())
+	require.NoError(t, err)
+	assert.True(t, called)
+}
+
+func TestNewClientWithOptions_TLS(t *testing.T) {
+	srv := newVersionServer(t, true, nil)
+	caFile := writeTempFile(t, "ca.crt", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}))
+
+	t.Run("unknown authority", func(t *testing.T) {
+		c, err := NewClientWithOptions(srv.URL)
+		require.NoError(t, err)
+
+		_, err = c.GetVersion(context.Background(), slog.Default())
+		require.Error(t, err)
+	})
+
+	t.Run("ca file", func(t *testing.T) {
+		c, err := NewClientWithOptions(srv.URL, WithTLSConfig(TLSConfig{CAFile: caFile}))
+		require.NoError(t, err)
+
+		_, err = c.GetVersion(context.Background(), slog.Default())
+		require.NoError(t, err)
+	})
+
+	t.Run("insecure skip verify", func(t *testing.T) {
+		c, err := NewClientWithOptions(srv.URL, WithTLSConfig(TLSConfig{InsecureSkipVerify: true}))
+		require.NoError(t, err)
+
+		_, err = c.GetVersion(context.Background(), slog.Default())
+		require.NoError(t, err)
+	})
+}
+
+func TestNewClientWithOptions_ClientCertificate(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r
+		_, _ = w.Write([]byte(`{"current":"4.0.0","latest":"4.0.0"}`))
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	certPEM, keyPEM := generateClientCertificate(t)
+	certFile := writeTempFile(t, "client.crt", certPEM)
+	keyFile := writeTempFile(t, "client.key", keyPEM)
+
+	c, err := NewClientWithOptions(srv.URL, WithTLSConfig(TLSConfig{
+		CertFile:           certFile,
+		KeyFile:            keyFile,
+		InsecureSkipVerify: true,
+	}))
+	require.NoError(t, err)
+
+	_, err = c.GetVersion(context.Background(), slog.Default())
+	require.NoError(t, err)
+
+	r := <-received
+	require.Len(t, r.TLS.PeerCertificates, 1)
+	assert.Equal(t, "mirakurun_exporter", r.TLS.PeerCertificates[0].Subject.CommonName)
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func generateClientCertificate(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "mirakurun_exporter"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
@@ -2,47 +2,186 @@ package mirakurun
 
 import (
 	"context"
-	"fmt"
+	"encoding/json"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 )
 
-type ProgramsResponse []struct {
-	ID                 int64           `json:"id"`
-	ServiceID          int             `json:"serviceId"`
-	NetworkID          int             `json:"networkId"`
-	Name               string          `json:"name"`
-	Type               int             `json:"type"`
-	LogoID             *int            `json:"logoId"`
-	HasLogoData        *bool           `json:"hasLogoData"`
-	RemoteControlKeyID *int            `json:"remoteControlKeyId"`
-	EpgReady           *bool           `json:"epgReady"`
-	EpgUpdatedAt       *int64          `json:"epgUpdatedAt"`
-	Channel            *ProgramChannel `json:"channel"`
+type ProgramsResponse []Program
+
+// Program is an EPG event. StartAt is a Unix time in milliseconds and Duration is in
+// milliseconds; use StartTime, EndTime and Length to get them as time values.
+type Program struct {
+	ID           int64                `json:"id"`
+	EventID      int                  `json:"eventId"`
+	ServiceID    int                  `json:"serviceId"`
+	NetworkID    int                  `json:"networkId"`
+	StartAt      int64                `json:"startAt"`
+	Duration     int64                `json:"duration"`
+	IsFree       bool                 `json:"isFree"`
+	Name         string               `json:"name"`
+	Description  string               `json:"description"`
+	Genres       []ProgramGenre       `json:"genres"`
+	Video        *ProgramVideo        `json:"video"`
+	Audios       []ProgramAudio       `json:"audios"`
+	Extended     map[string]string    `json:"extended"`
+	RelatedItems []ProgramRelatedItem `json:"relatedItems"`
+	Series       *ProgramSeries       `json:"series"`
+	// PF is set when the program was received from EIT[p/f], i.e. it is the present or the following program.
+	PF          bool `json:"_pf"`
+	IsFollowing bool `json:"_isFollowing"`
 }
 
-type ProgramChannel struct {
-	Type      string                 `json:"type"`
-	Channel   string                 `json:"channel"`
-	Name      string                 `json:"name"`
-	TsmfRelTs int                    `json:"tsmfRelTs"`
-	Services  map[string]interface{} `json:"services"`
+type ProgramGenre struct {
+	Lv1 int `json:"lv1"`
+	Lv2 int `json:"lv2"`
+	Un1 int `json:"un1"`
+	Un2 int `json:"un2"`
 }
 
-func (c *Client) GetPrograms(ctx context.Context, logger *slog.Logger) (*ProgramsResponse, error) {
-	resp, err := c.request(ctx, "GET", "/api/programs", nil, logger)
-	if err != nil {
-		return nil, err
+type ProgramVideo struct {
+	Type          string `json:"type"`
+	Resolution    string `json:"resolution"`
+	StreamContent int    `json:"streamContent"`
+	ComponentType int    `json:"componentType"`
+}
+
+type ProgramAudio struct {
+	ComponentType int      `json:"componentType"`
+	ComponentTag  int      `json:"componentTag"`
+	IsMain        bool     `json:"isMain"`
+	SamplingRate  int      `json:"samplingRate"`
+	Langs         []string `json:"langs"`
+}
+
+// ProgramRelatedItem is an event shared with, relayed to or moved to another service.
+// Type is one of "shared", "relay" or "movement".
+type ProgramRelatedItem struct {
+	Type      string `json:"type"`
+	NetworkID int    `json:"networkId"`
+	ServiceID int    `json:"serviceId"`
+	EventID   int    `json:"eventId"`
+}
+
+type ProgramSeries struct {
+	ID          int    `json:"id"`
+	Repeat      int    `json:"repeat"`
+	Pattern     int    `json:"pattern"`
+	ExpiresAt   int64  `json:"expiresAt"`
+	Episode     int    `json:"episode"`
+	LastEpisode int    `json:"lastEpisode"`
+	Name        string `json:"name"`
+}
+
+// programUndecidedDuration is the duration Mirakurun reports while the end time of a program is undecided.
+const programUndecidedDuration = 1
+
+// StartTime returns the start time of the program.
+func (p *Program) StartTime() time.Time {
+	return time.UnixMilli(p.StartAt)
+}
+
+// EndTime returns the end time of the program. It equals StartTime while the duration is undecided.
+func (p *Program) EndTime() time.Time {
+	return p.StartTime().Add(p.Length())
+}
+
+// Length returns the duration of the program, or 0 while it is undecided.
+func (p *Program) Length() time.Duration {
+	if p.IsDurationUndecided() {
+		return 0
 	}
+	return time.Duration(p.Duration) * time.Millisecond
+}
+
+// IsDurationUndecided reports whether the end time of the program is not yet announced.
+func (p *Program) IsDurationUndecided() bool {
+	return p.Duration == programUndecidedDuration
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("request failed with status code: %d", resp.StatusCode)
+// IsOnAir reports whether the program is on air at t. A program with an undecided duration
+// is considered on air from its start.
+func (p *Program) IsOnAir(t time.Time) bool {
+	if t.Before(p.StartTime()) {
+		return false
 	}
+	return p.IsDurationUndecided() || t.Before(p.EndTime())
+}
 
-	var programs ProgramsResponse
-	if err := decodeBody(resp, &programs); err != nil {
-		return nil, err
+// ExpiresTime returns the time the series information expires.
+func (s *ProgramSeries) ExpiresTime() time.Time {
+	return time.UnixMilli(s.ExpiresAt)
+}
+
+func (c *Client) GetPrograms(ctx context.Context, logger *slog.Logger) (*ProgramsResponse, error) {
+	return get[ProgramsResponse](ctx, c, "/api/programs", logger)
+}
+
+func (c *Client) GetProgram(ctx context.Context, id int64, logger *slog.Logger) (*Program, error) {
+	return get[Program](ctx, c, "/api/programs/"+strconv.FormatInt(id, 10), logger)
+}
+
+// ProgramsQuery filters /api/programs. Zero fields are not sent.
+type ProgramsQuery struct {
+	NetworkID int
+	ServiceID int
+	EventID   int
+}
+
+func (q ProgramsQuery) encode() string {
+	values := url.Values{}
+	if q.NetworkID != 0 {
+		values.Set("networkId", strconv.Itoa(q.NetworkID))
+	}
+	if q.ServiceID != 0 {
+		values.Set("serviceId", strconv.Itoa(q.ServiceID))
+	}
+	if q.EventID != 0 {
+		values.Set("eventId", strconv.Itoa(q.EventID))
 	}
+	return values.Encode()
+}
+
+// GetProgramsByService returns the programs matching query, typically those of one service.
+func (c *Client) GetProgramsByService(ctx context.Context, query ProgramsQuery, logger *slog.Logger) (*ProgramsResponse, error) {
+	path := "/api/programs"
+	if encoded := query.encode(); encoded != "" {
+		path += "?" + encoded
+	}
+	return get[ProgramsResponse](ctx, c, path, logger)
+}
+
+// ProgramCounts is the number of programs per service ID.
+type ProgramCounts map[int]int
+
+// programServiceID is the only part of a program needed to count programs per service.
+type programServiceID struct {
+	ServiceID int `json:"serviceId"`
+}
+
+// GetProgramCounts counts programs per service. Unlike GetPrograms it decodes /api/programs
+// one program at a time, so memory usage does not grow with the size of the EPG.
+func (c *Client) GetProgramCounts(ctx context.Context, logger *slog.Logger) (*ProgramCounts, error) {
+	return cached(ctx, c, "/api/programs", logger, func(ctx context.Context) (*ProgramCounts, error) {
+		resp, err := c.request(ctx, http.MethodGet, "/api/programs", nil, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		counts := make(ProgramCounts)
+		err = c.decode(resp, func(decoder *json.Decoder) error {
+			return decodeArray(decoder, func(program *programServiceID) error {
+				counts[program.ServiceID]++
+				return nil
+			})
+		})
+		if err != nil {
+			return nil, err
+		}
 
-	return &programs, nil
+		return &counts, nil
+	})
 }
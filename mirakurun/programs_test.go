@@ -1,15 +1,24 @@
 package mirakurun
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"runtime"
+	"runtime/metrics"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/nasshu2916/mirakurun_exporter/util"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetPrograms(t *testing.T) {
@@ -39,18 +48,48 @@ func TestGetPrograms(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	video := &ProgramVideo{Type: "mpeg2", Resolution: "1080i", StreamContent: 1, ComponentType: 179}
+	audios := []ProgramAudio{{ComponentType: 1, ComponentTag: 16, IsMain: true, SamplingRate: 48000, Langs: []string{"jpn"}}}
+	genres := []ProgramGenre{{Lv1: 0, Lv2: 0, Un1: 15, Un2: 15}}
+
 	want := &ProgramsResponse{
 		{
-			ID:        327360102403001,
-			ServiceID: 1024,
-			NetworkID: 32736,
-			Name:      "ニュース",
+			ID:          327360102403001,
+			EventID:     3001,
+			ServiceID:   1024,
+			NetworkID:   32736,
+			StartAt:     1749000000000,
+			Duration:    600000,
+			IsFree:      true,
+			Name:        "ニュース",
+			Description: "",
+			Video:       video,
+			Audios:      audios,
+			Genres:      genres,
+			RelatedItems: []ProgramRelatedItem{
+				{Type: "shared", ServiceID: 1024, EventID: 3001},
+				{Type: "shared", ServiceID: 1025, EventID: 3001},
+			},
+			PF:          true,
+			IsFollowing: true,
 		},
 		{
-			ID:        327360102403023,
-			ServiceID: 1024,
-			NetworkID: 32736,
-			Name:      "ニュース・気象情報🈑🈐",
+			ID:          327360102403023,
+			EventID:     3023,
+			ServiceID:   1024,
+			NetworkID:   32736,
+			StartAt:     1749000600000,
+			Duration:    300000,
+			IsFree:      true,
+			Name:        "ニュース・気象情報🈑🈐",
+			Description: "",
+			Video:       video,
+			Audios:      audios,
+			Genres:      genres,
+			RelatedItems: []ProgramRelatedItem{
+				{Type: "shared", ServiceID: 1024, EventID: 3002},
+				{Type: "shared", ServiceID: 1025, EventID: 3002},
+			},
 		},
 	}
 
@@ -58,3 +97,360 @@ func TestGetPrograms(t *testing.T) {
 		t.Fatalf("programs mismatch (-want +got):\n%s", diff)
 	}
 }
+
+func TestGetProgram(t *testing.T) {
+	c := newFixtureClient(t, "/api/programs/327360102403001", "programs_327360102403001.json")
+
+	program, err := c.GetProgram(context.Background(), 327360102403001, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, int64(327360102403001), program.ID)
+	assert.Equal(t, 3001, program.EventID)
+	assert.Equal(t, "ニュース", program.Name)
+	assert.True(t, program.PF)
+}
+
+func TestGetProgramsByService(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      ProgramsQuery
+		requestURI string
+	}{
+		{
+			name:       "正常系: サービス指定",
+			query:      ProgramsQuery{NetworkID: 32736, ServiceID: 1024},
+			requestURI: "/api/programs?networkId=32736&serviceId=1024",
+		},
+		{
+			name:       "正常系: イベント指定",
+			query:      ProgramsQuery{NetworkID: 32736, ServiceID: 1024, EventID: 3001},
+			requestURI: "/api/programs?eventId=3001&networkId=32736&serviceId=1024",
+		},
+		{
+			name:       "正常系: 指定なし",
+			query:      ProgramsQuery{},
+			requestURI: "/api/programs",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newFixtureClient(t, tt.requestURI, "programs.json")
+
+			programs, err := c.GetProgramsByService(context.Background(), tt.query, slog.Default())
+			require.NoError(t, err)
+			assert.Len(t, *programs, 2)
+		})
+	}
+}
+
+func TestProgram_UnmarshalSeriesAndExtended(t *testing.T) {
+	body := `{
+		"id": 327360102403100,
+		"eventId": 3100,
+		"serviceId": 1024,
+		"networkId": 32736,
+		"startAt": 1749003600000,
+		"duration": 1800000,
+		"isFree": false,
+		"name": "ドラマ",
+		"extended": {"番組内容": "第1話"},
+		"series": {
+			"id": 12,
+			"repeat": 0,
+			"pattern": 1,
+			"expiresAt": 1751000000000,
+			"episode": 1,
+			"lastEpisode": 10,
+			"name": "ドラマ"
+		}
+	}`
+
+	var program Program
+	require.NoError(t, json.Unmarshal([]byte(body), &program))
+
+	assert.Equal(t, map[string]string{"番組内容": "第1話"}, program.Extended)
+	assert.Equal(t, &ProgramSeries{ID: 12, Pattern: 1, ExpiresAt: 1751000000000, Episode: 1, LastEpisode: 10, Name: "ドラマ"}, program.Series)
+	assert.Equal(t, time.UnixMilli(1751000000000), program.Series.ExpiresTime())
+	assert.Nil(t, program.Video)
+	assert.False(t, program.PF)
+}
+
+func TestProgram_Times(t *testing.T) {
+	start := time.UnixMilli(1749000000000)
+
+	tests := []struct {
+		name      string
+		program   Program
+		wantEnd   time.Time
+		wantLen   time.Duration
+		undecided bool
+		onAir     map[time.Duration]bool
+	}{
+		{
+			name:    "正常系: 終了時刻が決まっている",
+			program: Program{StartAt: 1749000000000, Duration: 600000},
+			wantEnd: start.Add(10 * time.Minute),
+			wantLen: 10 * time.Minute,
+			onAir: map[time.Duration]bool{
+				-time.Second:     false,
+				0:                true,
+				9 * time.Minute:  true,
+				10 * time.Minute: false,
+			},
+		},
+		{
+			name:      "正常系: 終了時刻未定",
+			program:   Program{StartAt: 1749000000000, Duration: 1},
+			wantEnd:   start,
+			wantLen:   0,
+			undecided: true,
+			onAir: map[time.Duration]bool{
+				-time.Second: false,
+				0:            true,
+				time.Hour:    true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.True(t, tt.program.StartTime().Equal(start))
+			assert.True(t, tt.program.EndTime().Equal(tt.wantEnd))
+			assert.Equal(t, tt.wantLen, tt.program.Length())
+			assert.Equal(t, tt.undecided, tt.program.IsDurationUndecided())
+			for offset, want := range tt.onAir {
+				assert.Equal(t, want, tt.program.IsOnAir(start.Add(offset)), "offset %s", offset)
+			}
+		})
+	}
+}
+
+func TestGetProgramCounts(t *testing.T) {
+	testHelper := &util.TestHelper{}
+
+	responseBody := testHelper.ReadFile(t, "../test/mirakurun/programs.json")
+
+	srv := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			if _, err := w.Write([]byte(responseBody)); err != nil {
+				t.Errorf("failed to write response: %v", err)
+			}
+		}),
+	)
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, 1)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	counts, err := c.GetProgramCounts(context.Background(), slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &ProgramCounts{1024: 2}
+
+	if diff := cmp.Diff(want, counts); diff != "" {
+		t.Fatalf("program counts mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGetProgramCounts_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		opts  []Option
+		check func(t *testing.T, err error)
+	}{
+		{
+			name: "not an array",
+			body: `{"serviceId": 1024}`,
+			check: func(t *testing.T, err error) {
+				var decodeErr *DecodeError
+				require.ErrorAs(t, err, &decodeErr)
+			},
+		},
+		{
+			name: "truncated",
+			body: `[{"serviceId": 1024}, {"serviceId": 10`,
+			check: func(t *testing.T, err error) {
+				var decodeErr *DecodeError
+				require.ErrorAs(t, err, &decodeErr)
+			},
+		},
+		{
+			name: "too large",
+			body: `[{"serviceId": 1024}, {"serviceId": 1025}]`,
+			opts: []Option{WithMaxResponseSize(16)},
+			check: func(t *testing.T, err error) {
+				var sizeErr *ResponseTooLargeError
+				require.ErrorAs(t, err, &sizeErr)
+				assert.Equal(t, int64(16), sizeErr.Limit)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// Stream the body so that the size guard cannot rely on Content-Length.
+				w.(http.Flusher).Flush()
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			c, err := NewClientWithOptions(srv.URL, tt.opts...)
+			require.NoError(t, err)
+
+			_, err = c.GetProgramCounts(context.Background(), slog.Default())
+			tt.check(t, err)
+		})
+	}
+}
+
+func TestMaxResponseSize_ContentLength(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := []byte(`{"current":"4.0.0","latest":"4.0.0"}`)
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+
+	c, err := NewClientWithOptions(srv.URL, WithMaxResponseSize(10))
+	require.NoError(t, err)
+
+	_, err = c.GetVersion(context.Background(), slog.Default())
+
+	var sizeErr *ResponseTooLargeError
+	require.ErrorAs(t, err, &sizeErr)
+	assert.Equal(t, "/api/version", sizeErr.Path)
+}
+
+// syntheticPrograms returns an /api/programs body with n programs spread over 50 services,
+// built from the first program of the fixture.
+func syntheticPrograms(b *testing.B, n int) []byte {
+	b.Helper()
+
+	buf, err := os.ReadFile("../test/mirakurun/programs.json")
+	require.NoError(b, err)
+
+	var fixture []map[string]any
+	require.NoError(b, json.Unmarshal(buf, &fixture))
+
+	var body bytes.Buffer
+	body.WriteByte('[')
+	for i := 0; i < n; i++ {
+		program := fixture[0]
+		program["id"] = 327360000000000 + i
+		program["eventId"] = i
+		program["serviceId"] = 1024 + i%50
+		item, err := json.Marshal(program)
+		require.NoError(b, err)
+		if i > 0 {
+			body.WriteByte(',')
+		}
+		body.Write(item)
+	}
+	body.WriteByte(']')
+
+	return body.Bytes()
+}
+
+// peakHeap runs f and returns the highest heap usage sampled while it ran, above the usage before.
+// Unlike the allocations reported by b.ReportAllocs, it shows how much memory a decode needs at once.
+func peakHeap(f func()) uint64 {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	runtime.GC()
+	metrics.Read(sample)
+	base := sample[0].Value.Uint64()
+
+	done := make(chan struct{})
+	peak := make(chan uint64)
+	go func() {
+		samples := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+		var max uint64
+		for {
+			metrics.Read(samples)
+			if v := samples[0].Value.Uint64(); v > max {
+				max = v
+			}
+			select {
+			case <-done:
+				peak <- max
+				return
+			case <-time.After(20 * time.Microsecond):
+			}
+		}
+	}()
+	f()
+	close(done)
+
+	if max := <-peak; max > base {
+		return max - base
+	}
+	return 0
+}
+
+// benchmarkProgramCounts counts the programs per service of a synthetic EPG of n programs.
+// peak-heap-B/op grows with n when the whole response is unmarshalled, and stays hundreds of times
+// smaller when it is streamed, as the decoded programs are garbage right away.
+func benchmarkProgramCounts(b *testing.B, n int, count func(c *Client) (ProgramCounts, error)) {
+	body := syntheticPrograms(b, n)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, 60)
+	require.NoError(b, err)
+
+	var result ProgramCounts
+	peak := peakHeap(func() {
+		result, err = count(c)
+	})
+	require.NoError(b, err)
+	require.Len(b, result, min(n, 50))
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(body)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := count(c); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(peak), "peak-heap-B/op")
+}
+
+func BenchmarkProgramCounts(b *testing.B) {
+	for _, n := range []int{1000, 10000, 50000} {
+		// unmarshal is how programs were counted before GetProgramCounts: the whole response is decoded first.
+		b.Run("unmarshal/"+strconv.Itoa(n), func(b *testing.B) {
+			benchmarkProgramCounts(b, n, func(c *Client) (ProgramCounts, error) {
+				programs, err := c.GetPrograms(context.Background(), slog.Default())
+				if err != nil {
+					return nil, err
+				}
+				counts := make(ProgramCounts)
+				for _, program := range *programs {
+					counts[program.ServiceID]++
+				}
+				return counts, nil
+			})
+		})
+		b.Run("stream/"+strconv.Itoa(n), func(b *testing.B) {
+			benchmarkProgramCounts(b, n, func(c *Client) (ProgramCounts, error) {
+				counts, err := c.GetProgramCounts(context.Background(), slog.Default())
+				if err != nil {
+					return nil, err
+				}
+				return *counts, nil
+			})
+		})
+	}
+}
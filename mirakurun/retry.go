@@ -0,0 +1,57 @@
+package mirakurun
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// retryPolicy controls how idempotent requests are retried.
+// The zero value disables retries.
+type retryPolicy struct {
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// WithRetry retries failed GET requests up to maxRetries times.
+// The wait before each retry grows exponentially from minBackoff up to maxBackoff with random jitter,
+// and no retry is attempted when the wait would exceed the deadline of the request context.
+func WithRetry(maxRetries int, minBackoff, maxBackoff time.Duration) Option {
+	return func(cfg *clientConfig) error {
+		if maxRetries < 0 {
+			return fmt.Errorf("max retries must not be negative")
+		}
+		if minBackoff <= 0 || maxBackoff < minBackoff {
+			return fmt.Errorf("invalid retry backoff: min %s, max %s", minBackoff, maxBackoff)
+		}
+		cfg.retry = retryPolicy{
+			maxRetries: maxRetries,
+			minBackoff: minBackoff,
+			maxBackoff: maxBackoff,
+		}
+		return nil
+	}
+}
+
+// backoff returns the wait before the retry following the given attempt (0-based).
+// Half of the exponential delay is fixed and the other half is jitter.
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	delay := p.maxBackoff
+	if attempt < 32 {
+		if d := p.minBackoff << attempt; d > 0 && d < p.maxBackoff {
+			delay = d
+		}
+	}
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
+
+func isIdempotent(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+func isRetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+}
@@ -0,0 +1,147 @@
+package mirakurun
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFlakyServer(t *testing.T, failures int32, statusCode int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			w.WriteHeader(statusCode)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"current":"4.0.0","latest":"4.0.0"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &calls
+}
+
+func TestClient_Retry(t *testing.T) {
+	tests := []struct {
+		name          string
+		failures      int32
+		statusCode    int
+		maxRetries    int
+		wantErr       bool
+		wantCalls     int32
+		wantRetries   float64
+		wantExhausted float64
+	}{
+		{
+			name:        "recovers after transient errors",
+			failures:    2,
+			statusCode:  http.StatusServiceUnavailable,
+			maxRetries:  3,
+			wantCalls:   3,
+			wantRetries: 2,
+		},
+		{
+			name:          "gives up after max retries",
+			failures:      10,
+			statusCode:    http.StatusBadGateway,
+			maxRetries:    2,
+			wantErr:       true,
+			wantCalls:     3,
+			wantRetries:   2,
+			wantExhausted: 1,
+		},
+		{
+			name:       "does not retry client errors",
+			failures:   10,
+			statusCode: http.StatusNotFound,
+			maxRetries: 3,
+			wantErr:    true,
+			wantCalls:  1,
+		},
+		{
+			name:       "retries disabled",
+			failures:   1,
+			statusCode: http.StatusServiceUnavailable,
+			maxRetries: 0,
+			wantErr:    true,
+			wantCalls:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, calls := newFlakyServer(t, tt.failures, tt.statusCode)
+
+			c, err := NewClientWithOptions(srv.URL, WithRetry(tt.maxRetries, time.Millisecond, 5*time.Millisecond))
+			require.NoError(t, err)
+
+			_, err = c.GetVersion(context.Background(), slog.Default())
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, tt.wantCalls, calls.Load())
+			assert.Equal(t, tt.wantRetries, testutil.ToFloat64(c.metrics.retries.WithLabelValues("/api/version")))
+			assert.Equal(t, tt.wantExhausted, testutil.ToFloat64(c.metrics.retriesExhausted.WithLabelValues("/api/version")))
+		})
+	}
+}
+
+func TestClient_RetryRespectsDeadline(t *testing.T) {
+	srv, calls := newFlakyServer(t, 10, http.StatusServiceUnavailable)
+
+	c, err := NewClientWithOptions(srv.URL, WithRetry(5, time.Second, 2*time.Second))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	begin := time.Now()
+	_, err = c.GetVersion(ctx, slog.Default())
+	require.Error(t, err)
+
+	assert.Less(t, time.Since(begin), 200*time.Millisecond)
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.metrics.retriesExhausted.WithLabelValues("/api/version")))
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := retryPolicy{maxRetries: 10, minBackoff: 100 * time.Millisecond, maxBackoff: time.Second}
+
+	for attempt, want := range []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	} {
+		for i := 0; i < 20; i++ {
+			got := policy.backoff(attempt)
+			assert.GreaterOrEqual(t, got, want/2)
+			assert.LessOrEqual(t, got, want)
+		}
+	}
+
+	assert.LessOrEqual(t, policy.backoff(100), time.Second)
+}
+
+func TestWithRetry_Invalid(t *testing.T) {
+	_, err := NewClientWithOptions("http://localhost:40772", WithRetry(-1, time.Second, time.Second))
+	require.Error(t, err)
+
+	_, err = NewClientWithOptions("http://localhost:40772", WithRetry(1, time.Second, time.Millisecond))
+	require.Error(t, err)
+}
@@ -0,0 +1,236 @@
+package mirakurun
+
+import (
+	"encoding/json"
+	"log/slog"
+	"maps"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// WithStrictSchema compares every JSON response with the struct it is decoded into and reports
+// fields the struct does not know and fields no object of the response contained. Drift is exported
+// as the schema_unknown_fields and schema_missing_fields metrics and logged once per field; it
+// never fails a request. Responses decoded on the fly, such as GetProgramCounts, are not checked.
+func WithStrictSchema() Option {
+	return func(cfg *clientConfig) error {
+		cfg.strictSchema = true
+		return nil
+	}
+}
+
+// schemaDrift is the difference between a response and its struct.
+type schemaDrift struct {
+	unknown []string
+	missing []string
+}
+
+// checkSchema compares raw with the type t and returns the drift as sorted field paths, e.g.
+// "users[].streamSetting.channel.name". Elements of arrays and values of maps are merged,
+// so a field is only missing when none of them contained it.
+func checkSchema(raw json.RawMessage, t reflect.Type) (schemaDrift, error) {
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return schemaDrift{}, err
+	}
+
+	w := &schemaWalker{
+		unknown:  make(map[string]bool),
+		expected: make(map[string]bool),
+		present:  make(map[string]bool),
+	}
+	w.walk(t, value, "")
+
+	var drift schemaDrift
+	for field := range w.unknown {
+		drift.unknown = append(drift.unknown, field)
+	}
+	for field := range w.expected {
+		if !w.present[field] {
+			drift.missing = append(drift.missing, field)
+		}
+	}
+	sort.Strings(drift.unknown)
+	sort.Strings(drift.missing)
+	return drift, nil
+}
+
+var rawMessageType = reflect.TypeFor[json.RawMessage]()
+
+type schemaWalker struct {
+	unknown  map[string]bool
+	expected map[string]bool
+	present  map[string]bool
+}
+
+func (w *schemaWalker) walk(t reflect.Type, value any, path string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == rawMessageType {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]any)
+		if !ok {
+			return
+		}
+		fields := jsonFields(t)
+		for key, fieldValue := range object {
+			name, ok := matchField(fields, key)
+			if !ok {
+				w.unknown[joinField(path, key)] = true
+				continue
+			}
+			w.present[joinField(path, name)] = true
+			w.walk(fields[name], fieldValue, joinField(path, name))
+		}
+		for name := range fields {
+			w.expected[joinField(path, name)] = true
+		}
+	case reflect.Slice, reflect.Array:
+		items, ok := value.([]any)
+		if !ok {
+			return
+		}
+		itemPath := path
+		if path != "" {
+			itemPath += "[]"
+		}
+		for _, item := range items {
+			w.walk(t.Elem(), item, itemPath)
+		}
+	case reflect.Map:
+		object, ok := value.(map[string]any)
+		if !ok {
+			return
+		}
+		for _, item := range object {
+			w.walk(t.Elem(), item, joinField(path, "*"))
+		}
+	}
+}
+
+// jsonFields returns the types of the fields of struct t by JSON name.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// matchField finds the field a JSON key is decoded into. Like encoding/json, an exact match is
+// preferred and the name is matched case-insensitively otherwise.
+func matchField(fields map[string]reflect.Type, key string) (string, bool) {
+	if _, ok := fields[key]; ok {
+		return key, true
+	}
+	for name := range fields {
+		if strings.EqualFold(name, key) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func joinField(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+var (
+	schemaUnknownFieldsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "schema_unknown_fields"),
+		"Fields of the last Mirakurun response of an endpoint that the exporter does not know, reported when strict schema checking is enabled",
+		[]string{"endpoint", "field"},
+		nil,
+	)
+	schemaMissingFieldsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "schema_missing_fields"),
+		"Fields expected by the exporter that the last Mirakurun response of an endpoint lacked, reported when strict schema checking is enabled",
+		[]string{"endpoint", "field"},
+		nil,
+	)
+)
+
+// schemaReport keeps the drift of the last response of each endpoint and the drift already logged.
+// It is exported as a whole, so a scrape never sees the drift of an endpoint half replaced.
+type schemaReport struct {
+	mu     sync.Mutex
+	drift  map[string]schemaDrift
+	warned map[string]bool
+}
+
+func newSchemaReport() *schemaReport {
+	return &schemaReport{
+		drift:  make(map[string]schemaDrift),
+		warned: make(map[string]bool),
+	}
+}
+
+// recordSchemaDrift replaces the drift of endpoint with the drift of its latest response,
+// and logs fields not logged before.
+func (c *Client) recordSchemaDrift(endpoint string, drift schemaDrift, logger *slog.Logger) {
+	report := c.schemaReport
+	report.mu.Lock()
+	defer report.mu.Unlock()
+
+	if len(drift.unknown) == 0 && len(drift.missing) == 0 {
+		delete(report.drift, endpoint)
+	} else {
+		report.drift[endpoint] = drift
+	}
+
+	for _, field := range drift.unknown {
+		if key := "unknown " + endpoint + " " + field; !report.warned[key] {
+			report.warned[key] = true
+			logger.Warn("mirakurun response has a field unknown to the exporter", "endpoint", endpoint, "field", field)
+		}
+	}
+	for _, field := range drift.missing {
+		if key := "missing " + endpoint + " " + field; !report.warned[key] {
+			report.warned[key] = true
+			logger.Warn("mirakurun response lacks a field expected by the exporter", "endpoint", endpoint, "field", field)
+		}
+	}
+}
+
+func (report *schemaReport) Describe(ch chan<- *prometheus.Desc) {
+	ch <- schemaUnknownFieldsDesc
+	ch <- schemaMissingFieldsDesc
+}
+
+func (report *schemaReport) Collect(ch chan<- prometheus.Metric) {
+	report.mu.Lock()
+	drift := maps.Clone(report.drift)
+	report.mu.Unlock()
+
+	for endpoint, d := range drift {
+		for _, field := range d.unknown {
+			ch <- prometheus.MustNewConstMetric(schemaUnknownFieldsDesc, prometheus.GaugeValue, 1, endpoint, field)
+		}
+		for _, field := range d.missing {
+			ch <- prometheus.MustNewConstMetric(schemaMissingFieldsDesc, prometheus.GaugeValue, 1, endpoint, field)
+		}
+	}
+}
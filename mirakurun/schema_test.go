@@ -0,0 +1,167 @@
+package mirakurun
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckSchema(t *testing.T) {
+	type item struct {
+		Name  string            `json:"name"`
+		Count int               `json:"count"`
+		Data  json.RawMessage   `json:"data"`
+		Tags  map[string]string `json:"tags"`
+	}
+	type response struct {
+		ID    int              `json:"id"`
+		EPG   bool             `json:"EPG"`
+		Items []item           `json:"items"`
+		ByKey map[string]*item `json:"byKey"`
+	}
+
+	tests := []struct {
+		name        string
+		body        string
+		wantUnknown []string
+		wantMissing []string
+	}{
+		{
+			name: "正常系: 差分なし",
+			body: `{"id": 1, "epg": true, "items": [{"name": "a", "count": 1, "data": {"x": 1}, "tags": {}}], "byKey": {}}`,
+		},
+		{
+			name:        "正常系: 未知のフィールド",
+			body:        `{"id": 1, "EPG": true, "items": [{"name": "a", "count": 1, "data": null, "tags": {}, "color": "red"}], "byKey": {"k": {"name": "b", "count": 2, "data": 1, "tags": {}, "size": 3}}, "extra": 1}`,
+			wantUnknown: []string{"byKey.*.size", "extra", "items[].color"},
+		},
+		{
+			name:        "正常系: 配列の要素はまとめて判定する",
+			body:        `{"id": 1, "EPG": true, "items": [{"name": "a", "data": 1, "tags": {}}, {"name": "b", "count": 1, "data": 1}], "byKey": {}}`,
+			wantMissing: nil,
+		},
+		{
+			name:        "正常系: 欠けているフィールド",
+			body:        `{"items": [{"name": "a"}], "byKey": {}}`,
+			wantMissing: []string{"EPG", "id", "items[].count", "items[].data", "items[].tags"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			drift, err := checkSchema(json.RawMessage(tt.body), reflect.TypeFor[response]())
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantUnknown, drift.unknown)
+			assert.Equal(t, tt.wantMissing, drift.missing)
+		})
+	}
+}
+
+func TestClient_StrictSchema(t *testing.T) {
+	body := `{"current": "4.0.0", "latest": "4.0.1", "channel": "stable"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+	c, err := NewClientWithOptions(srv.URL, WithStrictSchema())
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		version, err := c.GetVersion(context.Background(), logger)
+		require.NoError(t, err)
+		assert.Equal(t, "4.0.0", version.Current)
+	}
+
+	assert.NoError(t, testutil.CollectAndCompare(c.schemaReport, strings.NewReader(`
+# HELP mirakurun_exporter_schema_unknown_fields Fields of the last Mirakurun response of an endpoint that the exporter does not know, reported when strict schema checking is enabled
+# TYPE mirakurun_exporter_schema_unknown_fields gauge
+mirakurun_exporter_schema_unknown_fields{endpoint="version",field="channel"} 1
+`)))
+	assert.Equal(t, 1, strings.Count(logs.String(), "field=channel"), "drift is logged once")
+
+	// A later response without the drift clears the metrics of the endpoint.
+	body = `{"current": "4.0.0"}`
+	_, err = c.GetVersion(context.Background(), logger)
+	require.NoError(t, err)
+
+	assert.NoError(t, testutil.CollectAndCompare(c.schemaReport, strings.NewReader(`
+# HELP mirakurun_exporter_schema_missing_fields Fields expected by the exporter that the last Mirakurun response of an endpoint lacked, reported when strict schema checking is enabled
+# TYPE mirakurun_exporter_schema_missing_fields gauge
+mirakurun_exporter_schema_missing_fields{endpoint="version",field="latest"} 1
+`)))
+}
+
+func TestClient_SchemaDriftIsReplacedAtOnce(t *testing.T) {
+	c, err := NewClientWithOptions("http://localhost:40772", WithStrictSchema())
+	require.NoError(t, err)
+	logger := slog.New(slog.DiscardHandler)
+
+	drifts := []schemaDrift{
+		{unknown: []string{"a", "b"}, missing: []string{"c"}},
+		{unknown: []string{"d"}, missing: []string{"e", "f"}},
+	}
+	c.recordSchemaDrift("status", drifts[0], logger)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 1000 {
+			c.recordSchemaDrift("status", drifts[i%2], logger)
+		}
+	}()
+
+	// Every scrape sees the three fields of one response, never a partly replaced drift.
+	for {
+		select {
+		case <-done:
+			return
+		default:
+			require.Equal(t, 3, testutil.CollectAndCount(c.schemaReport))
+		}
+	}
+}
+
+func TestClient_StrictSchemaDisabled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"current": "4.0.0", "channel": "stable"}`))
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, 1)
+	require.NoError(t, err)
+
+	_, err = c.GetVersion(context.Background(), slog.Default())
+	require.NoError(t, err)
+
+	assert.Equal(t, 0, testutil.CollectAndCount(c.schemaReport))
+}
+
+func TestClient_StrictSchemaDecodeError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"current": 4}`))
+	}))
+	defer srv.Close()
+
+	c, err := NewClientWithOptions(srv.URL, WithStrictSchema())
+	require.NoError(t, err)
+
+	_, err = c.GetVersion(context.Background(), slog.Default())
+
+	var decodeErr *DecodeError
+	require.ErrorAs(t, err, &decodeErr)
+	assert.Equal(t, "current", decodeErr.Field)
+}
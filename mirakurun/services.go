@@ -2,12 +2,13 @@ package mirakurun
 
 import (
 	"context"
-	"fmt"
 	"log/slog"
-	"net/http"
+	"strconv"
 )
 
-type ServicesResponse []struct {
+type ServicesResponse []Service
+
+type Service struct {
 	ID                 int64          `json:"id"`
 	ServiceID          int            `json:"serviceId"`
 	NetworkID          int            `json:"networkId"`
@@ -30,19 +31,10 @@ type ServiceChannel struct {
 }
 
 func (c *Client) GetServices(ctx context.Context, logger *slog.Logger) (*ServicesResponse, error) {
-	resp, err := c.request(ctx, "GET", "/api/services", nil, logger)
-	if err != nil {
-		return nil, err
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("request failed with status code: %d", resp.StatusCode)
-	}
-
-	var services ServicesResponse
-	if err := decodeBody(resp, &services); err != nil {
-		return nil, err
-	}
+	return get[ServicesResponse](ctx, c, "/api/services", logger)
+}
 
-	return &services, nil
+// GetService returns a service by its ID, which is the network ID * 100000 + the service ID.
+func (c *Client) GetService(ctx context.Context, id int64, logger *slog.Logger) (*Service, error) {
+	return get[Service](ctx, c, "/api/services/"+strconv.FormatInt(id, 10), logger)
 }
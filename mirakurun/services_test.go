@@ -94,3 +94,29 @@ func TestGetServices(t *testing.T) {
 		t.Fatalf("services mismatch (-want +got):\n%s", diff)
 	}
 }
+
+func TestGetService(t *testing.T) {
+	c := newFixtureClient(t, "/api/services/3273601024", "services_3273601024.json")
+
+	service, err := c.GetService(context.Background(), 3273601024, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Service{
+		ID:                 3273601024,
+		ServiceID:          1024,
+		NetworkID:          32736,
+		Name:               "ＮＨＫ総合１・東京",
+		Type:               1,
+		HasLogoData:        true,
+		RemoteControlKeyID: 1,
+		EpgReady:           true,
+		EpgUpdatedAt:       1749000000000,
+		Channel:            ServiceChannel{Type: "GR", Channel: "T27"},
+	}
+
+	if diff := cmp.Diff(want, service); diff != "" {
+		t.Fatalf("service mismatch (-want +got):\n%s", diff)
+	}
+}
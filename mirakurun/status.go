@@ -2,9 +2,7 @@ package mirakurun
 
 import (
 	"context"
-	"fmt"
 	"log/slog"
-	"net/http"
 )
 
 type StatusResponse struct {
@@ -68,21 +66,7 @@ type TimerAccuracyValue struct {
 }
 
 func (c *Client) GetStatus(ctx context.Context, logger *slog.Logger) (*StatusResponse, error) {
-	resp, err := c.request(ctx, "GET", "/api/status", nil, logger)
-	if err != nil {
-		return nil, err
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("request failed with status code: %d", resp.StatusCode)
-	}
-
-	var status StatusResponse
-	if err := decodeBody(resp, &status); err != nil {
-		return nil, err
-	}
-
-	return &status, nil
+	return get[StatusResponse](ctx, c, "/api/status", logger)
 }
 
 func (timerAccuracyValue *TimerAccuracyValue) GetValue(field string) float64 {
@@ -0,0 +1,78 @@
+package mirakurun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// defaultStreamBackoff is the wait before reconnecting a stream, growing while Mirakurun stays unreachable.
+var defaultStreamBackoff = retryPolicy{minBackoff: time.Second, maxBackoff: 30 * time.Second}
+
+var errStreamClosed = errors.New("stream closed by server")
+
+// WithStreamBackoff sets the wait before reconnecting a stream such as SubscribeEvents.
+// The wait grows exponentially from minBackoff up to maxBackoff with random jitter
+// and is reset once a connection succeeds.
+func WithStreamBackoff(minBackoff, maxBackoff time.Duration) Option {
+	return func(cfg *clientConfig) error {
+		if minBackoff <= 0 || maxBackoff < minBackoff {
+			return fmt.Errorf("invalid stream backoff: min %s, max %s", minBackoff, maxBackoff)
+		}
+		cfg.streamBackoff = retryPolicy{minBackoff: minBackoff, maxBackoff: maxBackoff}
+		return nil
+	}
+}
+
+// stream follows path and passes the response body to fn, reconnecting whenever the request fails
+// or the body ends. It only returns when ctx is done, with the error of ctx.
+func (c *Client) stream(ctx context.Context, path string, logger *slog.Logger, fn func(body io.Reader) error) error {
+	route := routeOf(path)
+	for attempt := 0; ; attempt++ {
+		connected, err := c.follow(ctx, path, logger, fn)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if connected {
+			attempt = 0
+		}
+
+		wait := c.streamBackoff.backoff(attempt)
+		c.metrics.streamReconnects.WithLabelValues(route).Inc()
+		logger.Warn("mirakurun stream disconnected, reconnecting", "path", path, "wait_seconds", wait.Seconds(), "err", err)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// follow connects to path once and reads the body with fn until it ends.
+// It reports whether the connection succeeded.
+func (c *Client) follow(ctx context.Context, path string, logger *slog.Logger, fn func(body io.Reader) error) (bool, error) {
+	resp, err := c.send(ctx, c.streamClient, http.MethodGet, path, nil, logger)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	gauge := c.metrics.streamConnected.WithLabelValues(routeOf(path))
+	gauge.Set(1)
+	defer gauge.Set(0)
+	logger.Debug("mirakurun stream connected", "path", path)
+
+	if err := fn(resp.Body); err != nil {
+		return true, err
+	}
+	return true, errStreamClosed
+}
@@ -2,12 +2,13 @@ package mirakurun
 
 import (
 	"context"
-	"fmt"
 	"log/slog"
-	"net/http"
+	"strconv"
 )
 
-type TunersResponse []struct {
+type TunersResponse []Tuner
+
+type Tuner struct {
 	Index       int         `json:"index"`
 	Name        string      `json:"name"`
 	Types       []string    `json:"types"`
@@ -57,19 +58,17 @@ type TunerStreamInfo struct {
 }
 
 func (c *Client) GetTuners(ctx context.Context, logger *slog.Logger) (*TunersResponse, error) {
-	resp, err := c.request(ctx, "GET", "/api/tuners", nil, logger)
-	if err != nil {
-		return nil, err
-	}
+	return get[TunersResponse](ctx, c, "/api/tuners", logger)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("request failed with status code: %d", resp.StatusCode)
-	}
+type TunerProcess struct {
+	PID int `json:"pid"`
+}
 
-	var tunersResponse TunersResponse
-	if err := decodeBody(resp, &tunersResponse); err != nil {
-		return nil, fmt.Errorf("failed to decode response body: %w", err)
-	}
+func (c *Client) GetTuner(ctx context.Context, index int, logger *slog.Logger) (*Tuner, error) {
+	return get[Tuner](ctx, c, "/api/tuners/"+strconv.Itoa(index), logger)
+}
 
-	return &tunersResponse, nil
+func (c *Client) GetTunerProcess(ctx context.Context, index int, logger *slog.Logger) (*TunerProcess, error) {
+	return get[TunerProcess](ctx, c, "/api/tuners/"+strconv.Itoa(index)+"/process", logger)
 }
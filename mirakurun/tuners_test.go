@@ -127,3 +127,61 @@ func TestGetTuners(t *testing.T) {
 		t.Fatalf("tuners mismatch (-want +got):\n%s", diff)
 	}
 }
+
+func TestGetTuner(t *testing.T) {
+	c := newFixtureClient(t, "/api/tuners/1", "tuners_1.json")
+
+	tuner, err := c.GetTuner(context.Background(), 1, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Tuner{
+		Index:   1,
+		Name:    "Tuner (Terrestrial) #2",
+		Types:   []string{"GR"},
+		Command: "recisdb tune --device /dev/pt3video3 --channel T27 -",
+		PID:     5978,
+		Users: []TunerUser{
+			{
+				ID:  "192.168.1.10:53833",
+				URL: "/api/channels/GR/T27/stream?decode=1",
+				StreamSetting: TunerStreamSetting{
+					Channel: TunerStreamSettingChannel{
+						Name:    "NHK総合・東京",
+						Type:    "GR",
+						Channel: "T27",
+						CommandVars: map[string]interface{}{
+							"satellite": " ",
+						},
+					},
+					NetworkID: 32736,
+					ParseEIT:  true,
+				},
+				StreamInfo: map[int]TunerStreamInfo{
+					0:  {Packet: 10},
+					16: {Packet: 20},
+				},
+			},
+		},
+		IsAvailable: true,
+		IsUsing:     true,
+	}
+
+	if diff := cmp.Diff(want, tuner); diff != "" {
+		t.Fatalf("tuner mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGetTunerProcess(t *testing.T) {
+	c := newFixtureClient(t, "/api/tuners/1/process", "tuners_1_process.json")
+
+	process, err := c.GetTunerProcess(context.Background(), 1, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(&TunerProcess{PID: 5978}, process); diff != "" {
+		t.Fatalf("tuner process mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,34 @@
+// Package mirakuruntest provides a fake Mirakurun for tests of code using the mirakurun package.
+//
+// The fake serves a fixture directory with fixture.Handler, which documents the layout, and
+// faults are injected with fixture.Fault.
+package mirakuruntest
+
+import (
+	"io/fs"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nasshu2916/mirakurun_exporter/mirakurun/fixture"
+)
+
+// Server is a fake Mirakurun listening on a local port.
+type Server struct {
+	*httptest.Server
+	*fixture.Handler
+}
+
+// NewServer starts a Server serving the fixtures in fsys. It is closed when tb completes.
+func NewServer(tb testing.TB, fsys fs.FS) *Server {
+	tb.Helper()
+	handler := fixture.NewHandler(fsys)
+	s := &Server{Server: httptest.NewServer(handler), Handler: handler}
+	tb.Cleanup(s.Close)
+	return s
+}
+
+// Close ends the open streams and shuts the server down.
+func (s *Server) Close() {
+	s.Handler.Close()
+	s.Server.Close()
+}
@@ -0,0 +1,160 @@
+package mirakuruntest_test
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nasshu2916/mirakurun_exporter/mirakurun"
+	"github.com/nasshu2916/mirakurun_exporter/mirakurun/fixture"
+	"github.com/nasshu2916/mirakurun_exporter/mirakuruntest"
+)
+
+func newClient(t *testing.T, srv *mirakuruntest.Server, opts ...mirakurun.Option) *mirakurun.Client {
+	t.Helper()
+	client, err := mirakurun.NewClientWithOptions(srv.URL, append([]mirakurun.Option{mirakurun.WithTimeout(time.Second)}, opts...)...)
+	require.NoError(t, err)
+	return client
+}
+
+func TestServer_Fixtures(t *testing.T) {
+	srv := mirakuruntest.NewServer(t, os.DirFS("../test/mirakurun"))
+	client := newClient(t, srv)
+	ctx := context.Background()
+	logger := slog.Default()
+
+	status, err := client.GetStatus(ctx, logger)
+	require.NoError(t, err)
+	assert.NotEmpty(t, status.Version)
+
+	process, err := client.GetTunerProcess(ctx, 1, logger)
+	require.NoError(t, err)
+	assert.NotZero(t, process.PID)
+
+	logLines, err := client.GetLog(ctx, logger)
+	require.NoError(t, err)
+	assert.NotEmpty(t, *logLines)
+
+	_, err = client.GetTuner(ctx, 99, logger)
+	var statusErr *mirakurun.StatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+
+	assert.Equal(t, 1, srv.Requests("/api/status"))
+	assert.Equal(t, 1, srv.Requests("/api/tuners/99"))
+}
+
+func TestServer_Set(t *testing.T) {
+	srv := mirakuruntest.NewServer(t, os.DirFS("../test/mirakurun"))
+	client := newClient(t, srv)
+	ctx := context.Background()
+	logger := slog.Default()
+
+	require.NoError(t, srv.SetJSON("/api/version", mirakurun.VersionResponse{Current: "9.9.9", Latest: "9.9.9"}))
+	version, err := client.GetVersion(ctx, logger)
+	require.NoError(t, err)
+	assert.Equal(t, "9.9.9", version.Current)
+
+	srv.Set("/api/version", nil)
+	_, err = client.GetVersion(ctx, logger)
+	var statusErr *mirakurun.StatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+
+	srv.Restore("/api/version")
+	version, err = client.GetVersion(ctx, logger)
+	require.NoError(t, err)
+	assert.NotEqual(t, "9.9.9", version.Current)
+}
+
+func TestServer_Faults(t *testing.T) {
+	tests := []struct {
+		name    string
+		fault   fixture.Fault
+		wantErr any
+	}{
+		{
+			name:    "エラー系: 500",
+			fault:   fixture.Fault{StatusCode: http.StatusInternalServerError},
+			wantErr: new(*mirakurun.StatusError),
+		},
+		{
+			name:    "エラー系: 途中で切れた JSON",
+			fault:   fixture.Fault{Truncate: true},
+			wantErr: new(*mirakurun.DecodeError),
+		},
+		{
+			name:    "エラー系: 接続のリセット",
+			fault:   fixture.Fault{Reset: true},
+			wantErr: new(*mirakurun.RequestError),
+		},
+		{
+			name:    "エラー系: 遅延によるタイムアウト",
+			fault:   fixture.Fault{Latency: 2 * time.Second},
+			wantErr: new(*mirakurun.TimeoutError),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := mirakuruntest.NewServer(t, os.DirFS("../test/mirakurun"))
+			client := newClient(t, srv)
+			ctx := context.Background()
+			logger := slog.Default()
+
+			srv.SetFault("/api/tuners", tt.fault)
+			_, err := client.GetTuners(ctx, logger)
+			assert.ErrorAs(t, err, tt.wantErr)
+
+			_, err = client.GetStatus(ctx, logger)
+			assert.NoError(t, err, "other endpoints are not affected")
+
+			srv.ClearFaults()
+			_, err = client.GetTuners(ctx, logger)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestServer_FaultCount(t *testing.T) {
+	srv := mirakuruntest.NewServer(t, os.DirFS("../test/mirakurun"))
+	client := newClient(t, srv, mirakurun.WithRetry(2, time.Millisecond, time.Millisecond))
+
+	// The retried request succeeds once the fault is used up.
+	srv.SetFault(fixture.AllEndpoints, fixture.Fault{StatusCode: http.StatusServiceUnavailable, Count: 2})
+	_, err := client.GetStatus(context.Background(), slog.Default())
+	require.NoError(t, err)
+	assert.Equal(t, 3, srv.Requests("/api/status"))
+}
+
+func TestServer_Stream(t *testing.T) {
+	srv := mirakuruntest.NewServer(t, os.DirFS("../test/mirakurun"))
+	client := newClient(t, srv)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := make(chan *mirakurun.Event)
+	go func() {
+		_ = client.SubscribeEvents(ctx, func(event *mirakurun.Event) {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+			}
+		}, slog.Default())
+	}()
+
+	select {
+	case event := <-events:
+		assert.NotEmpty(t, event.Resource)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+	assert.Equal(t, 1, srv.Requests("/api/events/stream"))
+}